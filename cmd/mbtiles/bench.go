@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	register("bench", "[options] file.mbtiles",
+		"measure tile read throughput with concurrent readers", bench)
+}
+
+type tilecoord struct{ z, x, y int }
+
+func bench(args []string) error {
+	fs := flagset("bench")
+	dur := fs.Duration("d", 2*time.Second, "duration of each run")
+	conc := fs.String("c", "1,2,4,8,16,32", "comma separated list of reader counts")
+	sample := fs.Int("sample", 10000, "number of tiles sampled for reads")
+	fn := parseargs(fs, args, 1, 1)[0]
+
+	var levels []int
+	for _, s := range strings.Split(*conc, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid reader count %q", s)
+		}
+		levels = append(levels, n)
+	}
+
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer mbt.Close()
+
+	coords, err := sampletiles(mbt, *sample)
+	if err != nil {
+		return err
+	}
+	if len(coords) == 0 {
+		return errors.New("no tiles")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "readers\ttiles/s\tMiB/s\tspeedup\t")
+	var base float64
+	for _, n := range levels {
+		tiles, bytes, err := benchrun(mbt, coords, n, *dur)
+		if err != nil {
+			return err
+		}
+		secs := dur.Seconds()
+		rate := float64(tiles) / secs
+		if base == 0 {
+			base = rate
+		}
+		fmt.Fprintf(tw, "%d\t%.0f\t%.1f\t%.2fx\t\n", n, rate, float64(bytes)/secs/(1<<20), rate/base)
+	}
+	return tw.Flush()
+}
+
+// sampletiles returns the coordinates of up to n tiles
+// selected randomly from mbt.
+func sampletiles(mbt *mbtiles.Map, n int) ([]tilecoord, error) {
+	var v []tilecoord
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	for i := 0; it.Next(); i++ {
+		z, x, y, _ := it.Tile()
+		if len(v) < n {
+			v = append(v, tilecoord{z, x, y})
+		} else if k := rand.Intn(i + 1); k < n {
+			v[k] = tilecoord{z, x, y}
+		}
+	}
+	return v, it.Err()
+}
+
+// benchrun reads random tiles from coords with n goroutines
+// for duration d, and returns the number of tiles and bytes read.
+func benchrun(mbt *mbtiles.Map, coords []tilecoord, n int, d time.Duration) (tiles, bytes int64, err error) {
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	deadline := time.Now().Add(d)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			var nt, nb int64
+			var rerr error
+			for time.Now().Before(deadline) {
+				c := coords[r.Intn(len(coords))]
+				data, err := mbt.GetTile(c.z, c.x, c.y)
+				if err != nil {
+					rerr = err
+					break
+				}
+				nt++
+				nb += int64(len(data))
+			}
+			mtx.Lock()
+			tiles += nt
+			bytes += nb
+			if err == nil {
+				err = rerr
+			}
+			mtx.Unlock()
+		}(int64(i))
+	}
+	wg.Wait()
+	return tiles, bytes, err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/pmtiles"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	register("convert", "[options] src dst",
+		"convert between mbtiles and pmtiles files, direction is chosen by extension", convert)
+}
+
+func convert(args []string) error {
+	fs := flagset("convert")
+	dedup := fs.Bool("dedup", false, "store identical tiles only once in the created mbtiles file")
+	a := parseargs(fs, args, 2, 2)
+
+	ispm := func(fn string) bool {
+		return strings.EqualFold(filepath.Ext(fn), ".pmtiles")
+	}
+	switch {
+	case !ispm(a[0]) && ispm(a[1]):
+		mbt, err := mbtiles.Open(a[0])
+		if err != nil {
+			return err
+		}
+		defer mbt.Close()
+		return pmtiles.FromMBTiles(mbt, a[1])
+	case ispm(a[0]) && !ispm(a[1]):
+		r, err := pmtiles.Open(a[0])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		schema := mbtiles.FlatSchema
+		if *dedup {
+			schema = mbtiles.DedupSchema
+		}
+		return pmtiles.ToMBTiles(r, a[1], schema)
+	}
+	return errors.New("exactly one of src and dst must be a .pmtiles file")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+)
+
+func init() {
+	register("export", "[options] file.mbtiles dir",
+		"export tiles into a {z}/{x}/{y}.ext directory tree", export)
+	register("import", "[options] dir file.mbtiles",
+		"create an mbtiles file from a {z}/{x}/{y}.ext directory tree", importdir)
+}
+
+func export(args []string) error {
+	fs := flagset("export")
+	opt := new(mbtiles.DirOptions)
+	fs.BoolVar(&opt.TMS, "tms", false, "use TMS row numbering instead of XYZ")
+	fs.BoolVar(&opt.Grids, "grids", false, "export UTFGrids as {z}/{x}/{y}.grid.json")
+	fs.BoolVar(&opt.NoMetadata, "nometadata", false, "don't write metadata.json")
+	fs.StringVar(&opt.Ext, "ext", "", "tile file extension, detected from tile data if empty")
+	a := parseargs(fs, args, 2, 2)
+
+	mbt, err := mbtiles.Open(a[0])
+	if err != nil {
+		return err
+	}
+	defer mbt.Close()
+	return mbtiles.Export(mbt, a[1], opt)
+}
+
+func importdir(args []string) error {
+	fs := flagset("import")
+	opt := new(mbtiles.DirOptions)
+	fs.BoolVar(&opt.TMS, "tms", false, "file names use TMS row numbering instead of XYZ")
+	fs.BoolVar(&opt.Grids, "grids", false, "import UTFGrids from {z}/{x}/{y}.grid.json")
+	fs.BoolVar(&opt.NoMetadata, "nometadata", false, "don't read metadata.json")
+	dedup := fs.Bool("dedup", false, "store identical tiles only once")
+	a := parseargs(fs, args, 2, 2)
+
+	if *dedup {
+		opt.Schema = mbtiles.DedupSchema
+	}
+	return mbtiles.Import(a[0], a[1], opt)
+}
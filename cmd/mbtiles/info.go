@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+func init() {
+	register("info", "[-json] file.mbtiles", "print metadata and tile counts", info)
+}
+
+type infozoom struct {
+	Zoom  int `json:"zoom"`
+	Count int `json:"count"`
+}
+
+type infoout struct {
+	File     string            `json:"file"`
+	Size     int64             `json:"size"`
+	Schema   string            `json:"schema"`
+	Metadata map[string]string `json:"metadata"`
+	Count    int               `json:"count"`
+	Zooms    []infozoom        `json:"zooms"`
+}
+
+func info(args []string) error {
+	fs := flagset("info")
+	jsonout := fs.Bool("json", false, "JSON output")
+	fn := parseargs(fs, args, 1, 1)[0]
+
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer mbt.Close()
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+	st, err := mbt.Stats()
+	if err != nil {
+		return err
+	}
+
+	out := infoout{
+		File:     fn,
+		Size:     fi.Size(),
+		Schema:   mbt.Schema().String(),
+		Metadata: mbt.Metadata().Values(),
+		Count:    st.Count,
+		Zooms:    []infozoom{},
+	}
+	for _, zs := range st.Zooms {
+		out.Zooms = append(out.Zooms, infozoom{zs.Zoom, zs.Count})
+	}
+	if *jsonout {
+		return printjson(out)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "file:\t%s\n", out.File)
+	fmt.Fprintf(tw, "size:\t%s\n", bytesize(out.Size))
+	fmt.Fprintf(tw, "schema:\t%s\n", out.Schema)
+	fmt.Fprintf(tw, "tiles:\t%d\n", out.Count)
+	tw.Flush()
+
+	fmt.Println("\nmetadata:")
+	keys := make([]string, 0, len(out.Metadata))
+	for k := range out.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "  %s:\t%s\n", k, ellipsis(out.Metadata[k], 72))
+	}
+	tw.Flush()
+
+	fmt.Println("\ntiles per zoom:")
+	for _, z := range out.Zooms {
+		fmt.Fprintf(tw, "  %d:\t%d\n", z.Zoom, z.Count)
+	}
+	return tw.Flush()
+}
+
+// ellipsis shortens s to at most n runes
+func ellipsis(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
@@ -0,0 +1,112 @@
+// Command mbtiles inspects and manipulates MBTiles files.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string // arguments after command name
+	help  string
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(name, usage, help string, run func(args []string) error) {
+	commands[name] = &command{usage, help, run}
+}
+
+// errfail is returned by commands that produced their output,
+// but need to exit with a failure status
+var errfail = errors.New("failed")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mbtiles command [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", n, commands[n].help)
+	}
+	fmt.Fprintln(os.Stderr, "\nrun 'mbtiles command -h' for help on a command")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown command:", os.Args[1])
+		usage()
+	}
+	err := cmd.run(os.Args[2:])
+	if err == errfail {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mbtiles "+os.Args[1]+":", err)
+		os.Exit(1)
+	}
+}
+
+// flagset returns a FlagSet for command name
+func flagset(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		cmd := commands[name]
+		fmt.Fprintf(os.Stderr, "usage: mbtiles %s %s\n\n%s\n", name, cmd.usage, cmd.help)
+		if hasflags(fs) {
+			fmt.Fprintln(os.Stderr, "\noptions:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func hasflags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n != 0
+}
+
+// parseargs parses args into fs, and checks that the
+// number of remaining arguments is between min and max.
+// Negative max means no limit.
+func parseargs(fs *flag.FlagSet, args []string, min, max int) []string {
+	fs.Parse(args)
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Args()
+}
+
+func printjson(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// bytesize formats a byte count for humans
+func bytesize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	v, i := float64(n)/unit, 0
+	for ; v >= unit && i < 3; i++ {
+		v /= unit
+	}
+	return fmt.Sprintf("%.1f %ciB", v, "KMGT"[i])
+}
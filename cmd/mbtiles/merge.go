@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/merge"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	register("merge", "[options] src.mbtiles... dst.mbtiles",
+		"combine files into a new one", mergecmd)
+	register("patch", "[options] file.mbtiles patch.mbtiles",
+		"apply the tiles of a patch file onto a file, leaving it unchanged on failure", patchcmd)
+}
+
+func mergecmd(args []string) error {
+	fs := flagset("merge")
+	policy := fs.String("policy", "first", "tile conflict policy (first, last, composite or concat)")
+	dedup := fs.Bool("dedup", false, "store identical tiles only once in the created file")
+	jsonout := fs.Bool("json", false, "JSON output")
+	a := parseargs(fs, args, 2, -1)
+
+	opt := new(merge.Options)
+	var err error
+	if opt.Policy, err = merge.ParsePolicy(*policy); err != nil {
+		return err
+	}
+	if *dedup {
+		opt.Schema = mbtiles.DedupSchema
+	}
+	rep, err := merge.Merge(a[len(a)-1], a[:len(a)-1], opt)
+	if err != nil {
+		return err
+	}
+	return printmerge(rep, opt.Policy, *jsonout)
+}
+
+func patchcmd(args []string) error {
+	fs := flagset("patch")
+	policy := fs.String("policy", "last", "tile conflict policy (first, last, composite or concat)")
+	jsonout := fs.Bool("json", false, "JSON output")
+	a := parseargs(fs, args, 2, 2)
+
+	opt := new(merge.Options)
+	var err error
+	if opt.Policy, err = merge.ParsePolicy(*policy); err != nil {
+		return err
+	}
+	rep, err := merge.Patch(a[0], a[1], opt)
+	if err != nil {
+		return err
+	}
+	return printmerge(rep, opt.Policy, *jsonout)
+}
+
+func printmerge(rep *merge.Report, p merge.Policy, jsonout bool) error {
+	if jsonout {
+		return printjson(rep)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "tiles\t%d\n", rep.Tiles)
+	fmt.Fprintf(tw, "added\t%d\n", rep.Added)
+	fmt.Fprintf(tw, "conflicts\t%d\n", rep.Conflicts)
+	fmt.Fprintf(tw, "replaced (%v)\t%d\n", p, rep.Replaced)
+	if rep.GridsSkipped {
+		fmt.Fprintf(tw, "grids\tskipped, destination has no grid tables\n")
+	}
+	return tw.Flush()
+}
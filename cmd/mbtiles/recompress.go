@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"image/png"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+func init() {
+	register("recompress", "[options] src.mbtiles dst.mbtiles",
+		"re-encode raster tiles into a new file, keeping tiles that would get larger", recompress)
+}
+
+func recompress(args []string) error {
+	fs := flagset("recompress")
+	format := fs.String("format", "png", "format of re-encoded tiles (png, jpg or gif)")
+	colors := fs.Int("colors", 0, "create paletted png tiles with at most this many colors")
+	quality := fs.Int("quality", raster.JPEGQuality, "jpg quality")
+	workers := fs.Int("j", 0, "number of tiles encoded in parallel, the number of CPUs by default")
+	dedup := fs.Bool("dedup", false, "store identical tiles only once in the created file")
+	jsonout := fs.Bool("json", false, "JSON output")
+	a := parseargs(fs, args, 2, 2)
+
+	opt := &raster.RecompressOptions{
+		Encoder: raster.Encoder{
+			Format:      mbtiles.ParseFormat(*format),
+			Quality:     *quality,
+			Colors:      *colors,
+			Compression: png.BestCompression,
+		},
+		Workers: *workers,
+	}
+	if !raster.CanEncode(opt.Format) {
+		return fmt.Errorf("can't encode tiles in format %q", *format)
+	}
+	if *colors < 0 || *colors > 256 {
+		return fmt.Errorf("number of colors must be between 1 and 256")
+	}
+	if *dedup {
+		opt.Schema = mbtiles.DedupSchema
+	}
+
+	mbt, err := mbtiles.Open(a[0])
+	if err != nil {
+		return err
+	}
+	defer mbt.Close()
+	rep, err := raster.Recompress(mbt, a[1], opt)
+	if err != nil {
+		return err
+	}
+	if *jsonout {
+		return printjson(rep)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "tiles\t%d\n", rep.Tiles)
+	fmt.Fprintf(tw, "converted\t%d\n", rep.Converted)
+	fmt.Fprintf(tw, "kept, larger\t%d\n", rep.Larger)
+	fmt.Fprintf(tw, "kept, not raster or transparent\t%d\n", rep.Skipped)
+	var formats []string
+	for f := range rep.Formats {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	for _, f := range formats {
+		name := f
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(tw, "%s tiles\t%d\n", name, rep.Formats[f])
+	}
+	ratio := 100.0
+	if rep.InBytes != 0 {
+		ratio = float64(rep.OutBytes) * 100 / float64(rep.InBytes)
+	}
+	fmt.Fprintf(tw, "size\t%s -> %s (%.1f%%)\n", bytesize(rep.InBytes), bytesize(rep.OutBytes), ratio)
+	return tw.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	register("stats", "[-json] file.mbtiles",
+		"print tile size statistics and tile extent per zoom level", stats)
+}
+
+type bucketout struct {
+	Max   int `json:"max,omitempty"` // zero for the last bucket
+	Count int `json:"count"`
+}
+
+type zoomstatsout struct {
+	Zoom      int         `json:"zoom"`
+	Count     int         `json:"count"`
+	Bytes     int64       `json:"bytes"`
+	MinSize   int         `json:"minsize"`
+	MaxSize   int         `json:"maxsize"`
+	Extent    [4]int      `json:"extent"` // min col, min row, max col, max row (TMS)
+	Bounds    [4]float64  `json:"bounds"`
+	Outside   int         `json:"outside"`
+	Histogram []bucketout `json:"histogram"`
+}
+
+type statsout struct {
+	File      string         `json:"file"`
+	Declared  *[4]float64    `json:"declared_bounds"`
+	Count     int            `json:"count"`
+	Bytes     int64          `json:"bytes"`
+	Zooms     []zoomstatsout `json:"zooms"`
+	Histogram []bucketout    `json:"histogram"`
+}
+
+func histogram(h []int) []bucketout {
+	v := make([]bucketout, len(h))
+	for i, n := range h {
+		v[i].Count = n
+		if i < len(mbtiles.SizeBuckets) {
+			v[i].Max = mbtiles.SizeBuckets[i]
+		}
+	}
+	return v
+}
+
+func boundsarray(b mbtiles.MbtBounds) [4]float64 {
+	return [4]float64{b.W, b.S, b.E, b.N}
+}
+
+func stats(args []string) error {
+	fs := flagset("stats")
+	jsonout := fs.Bool("json", false, "JSON output")
+	fn := parseargs(fs, args, 1, 1)[0]
+
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer mbt.Close()
+	st, err := mbt.Stats()
+	if err != nil {
+		return err
+	}
+
+	out := statsout{
+		File:      fn,
+		Count:     st.Count,
+		Bytes:     st.Bytes,
+		Zooms:     []zoomstatsout{},
+		Histogram: histogram(st.Histogram),
+	}
+	if _, ok := mbt.Metadata().Values()["bounds"]; ok {
+		b := boundsarray(mbt.Metadata().Bounds)
+		out.Declared = &b
+	}
+	for _, zs := range st.Zooms {
+		out.Zooms = append(out.Zooms, zoomstatsout{
+			Zoom:      zs.Zoom,
+			Count:     zs.Count,
+			Bytes:     zs.Bytes,
+			MinSize:   zs.MinSize,
+			MaxSize:   zs.MaxSize,
+			Extent:    [4]int{zs.MinCol, zs.MinRow, zs.MaxCol, zs.MaxRow},
+			Bounds:    boundsarray(zs.Bounds),
+			Outside:   zs.Outside,
+			Histogram: histogram(zs.Histogram),
+		})
+	}
+	if *jsonout {
+		return printjson(out)
+	}
+
+	fmt.Printf("%s: %d tiles, %s\n", fn, out.Count, bytesize(out.Bytes))
+	if out.Declared != nil {
+		fmt.Printf("declared bounds: %s\n", fmtbounds(*out.Declared))
+	} else {
+		fmt.Println("declared bounds: none")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\nzoom\ttiles\tsize\tavg\tmin\tmax\tcolumns\trows\toutside\t")
+	for _, z := range out.Zooms {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%d-%d\t%d-%d\t%d\t\n",
+			z.Zoom, z.Count, bytesize(z.Bytes), bytesize(z.Bytes/int64(z.Count)),
+			bytesize(int64(z.MinSize)), bytesize(int64(z.MaxSize)),
+			z.Extent[0], z.Extent[2], z.Extent[1], z.Extent[3], z.Outside)
+	}
+	tw.Flush()
+
+	fmt.Println("\nextent per zoom (W,S,E,N):")
+	for _, z := range out.Zooms {
+		fmt.Printf("%4d  %s\n", z.Zoom, fmtbounds(z.Bounds))
+	}
+
+	fmt.Println("\nsize histogram:")
+	printhistogram(out.Histogram, out.Count)
+	return nil
+}
+
+func fmtbounds(b [4]float64) string {
+	return fmt.Sprintf("%.6f,%.6f,%.6f,%.6f", b[0], b[1], b[2], b[3])
+}
+
+func printhistogram(h []bucketout, total int) {
+	const width = 50
+	max := 0
+	for _, b := range h {
+		if b.Count > max {
+			max = b.Count
+		}
+	}
+	for i, b := range h {
+		var label string
+		if b.Max != 0 {
+			label = "<= " + bytesize(int64(b.Max))
+		} else {
+			label = " > " + bytesize(int64(h[i-1].Max))
+		}
+		bar := 0
+		if max != 0 {
+			bar = b.Count * width / max
+		}
+		pct := 0.0
+		if total != 0 {
+			pct = float64(b.Count) * 100 / float64(total)
+		}
+		fmt.Printf("%12s %8d %5.1f%% %s\n", label, b.Count, pct, strings.Repeat("#", bar))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"sort"
+	"strings"
+)
+
+func init() {
+	register("underzoom", "[options] file.mbtiles",
+		"create missing lower zoom levels of a raster tileset from higher ones", underzoom)
+}
+
+func underzoom(args []string) error {
+	fs := flagset("underzoom")
+	minzoom := fs.Int("minzoom", 0, "lowest zoom level to create")
+	format := fs.String("format", "", "format of created tiles (png, jpg or gif), the format of existing tiles by default")
+	filter := fs.String("filter", "bilinear", "resampling filter ("+filternames()+")")
+	a := parseargs(fs, args, 1, 1)
+
+	opt := &raster.UnderzoomOptions{MinZoom: *minzoom}
+	if *format != "" {
+		if opt.Format = mbtiles.ParseFormat(*format); !raster.CanEncode(opt.Format) {
+			return fmt.Errorf("can't encode tiles in format %q", *format)
+		}
+	}
+	var ok bool
+	if opt.Filter, ok = raster.Filters[*filter]; !ok {
+		return fmt.Errorf("unknown filter %q", *filter)
+	}
+	n, err := raster.Underzoom(a[0], opt)
+	if n != 0 {
+		fmt.Printf("%s: %d tiles created\n", a[0], n)
+	}
+	return err
+}
+
+func filternames() string {
+	var v []string
+	for n := range raster.Filters {
+		v = append(v, n)
+	}
+	sort.Strings(v)
+	return strings.Join(v, ", ")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+)
+
+func init() {
+	register("validate", "[-json] [-strict] file.mbtiles...",
+		"check files against the MBTiles specification, fail if there are errors", validate)
+}
+
+type finding struct {
+	Severity string `json:"severity"`
+	Key      string `json:"key,omitempty"`
+	Message  string `json:"message"`
+}
+
+type validateout struct {
+	File     string    `json:"file"`
+	Valid    bool      `json:"valid"`
+	Error    string    `json:"error,omitempty"`
+	Findings []finding `json:"findings"`
+}
+
+func validate(args []string) error {
+	fs := flagset("validate")
+	jsonout := fs.Bool("json", false, "JSON output")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	files := parseargs(fs, args, 1, -1)
+
+	var out []validateout
+	ok := true
+	for _, fn := range files {
+		v := validateout{File: fn, Findings: []finding{}}
+		r, err := validatefile(fn)
+		if err != nil {
+			v.Error = err.Error()
+		}
+		for _, f := range r {
+			v.Findings = append(v.Findings, finding{f.Severity.String(), f.Key, f.Message})
+		}
+		v.Valid = err == nil && !r.HasErrors() && (!*strict || len(r) == 0)
+		ok = ok && v.Valid
+		out = append(out, v)
+	}
+
+	if *jsonout {
+		if err := printjson(out); err != nil {
+			return err
+		}
+	} else {
+		for _, v := range out {
+			status := "ok"
+			if !v.Valid {
+				status = "FAILED"
+			}
+			fmt.Printf("%s: %s\n", v.File, status)
+			if v.Error != "" {
+				fmt.Println("  error:", v.Error)
+			}
+			for _, f := range v.Findings {
+				if f.Key != "" {
+					fmt.Printf("  %s: %s: %s\n", f.Severity, f.Key, f.Message)
+				} else {
+					fmt.Printf("  %s: %s\n", f.Severity, f.Message)
+				}
+			}
+		}
+	}
+	if !ok {
+		return errfail
+	}
+	return nil
+}
+
+func validatefile(fn string) (mbtiles.Report, error) {
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer mbt.Close()
+	return mbt.Validate()
+}
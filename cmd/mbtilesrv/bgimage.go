@@ -36,8 +36,8 @@ func init() {
 	bgimg = buf.Bytes()
 }
 
-func enable_bgimg() {
-	http.Handle(servepath, http.HandlerFunc(
+func enable_bgimg(mux *http.ServeMux) {
+	mux.Handle(servepath, http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
 			http.ServeContent(w, req, servepath,
 				time.Time{}, bytes.NewReader(bgimg))
@@ -172,6 +172,6 @@ func serve_cached(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func enable_cache(pth string) {
-	http.Handle(pth, http.StripPrefix(pth, http.HandlerFunc(serve_cached)))
+func enable_cache(mux *http.ServeMux, pth string) {
+	mux.Handle(pth, http.StripPrefix(pth, http.HandlerFunc(serve_cached)))
 }
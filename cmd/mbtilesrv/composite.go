@@ -0,0 +1,116 @@
+package main
+
+// composite tilesets blending raster tilesets
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compositeflags collects the specifications of composite tilesets
+type compositeflags []string
+
+func (c *compositeflags) String() string {
+	return strings.Join(*c, " ")
+}
+
+func (c *compositeflags) Set(s string) error {
+	*c = append(*c, s)
+	return nil
+}
+
+var composites compositeflags
+
+func init() {
+	flag.Var(&composites, "composite",
+		"serve `name=layer,layer@opacity,...` blending the named tilesets bottom to top, may be repeated")
+}
+
+// addcomposite mounts the composite tileset of spec
+// having the form name=layer,layer@opacity,...
+func (s *server) addcomposite(spec string) error {
+	n := strings.IndexByte(spec, '=')
+	if n <= 0 || n == len(spec)-1 {
+		return fmt.Errorf("composite %q: must be name=layer,layer@opacity,...", spec)
+	}
+	name := spec[:n]
+	var layers []raster.Layer
+	for _, l := range strings.Split(spec[n+1:], ",") {
+		lname, opacity := l, 1.0
+		if i := strings.LastIndexByte(l, '@'); i >= 0 {
+			var err error
+			lname = l[:i]
+			opacity, err = strconv.ParseFloat(l[i+1:], 64)
+			if err != nil || opacity < 0 || opacity > 1 {
+				return fmt.Errorf("composite %s: invalid opacity in %q", name, l)
+			}
+		}
+		ts := s.get(lname)
+		if ts == nil {
+			return fmt.Errorf("composite %s: unknown tileset %q", name, lname)
+		}
+		if ts.isvector() {
+			return fmt.Errorf("composite %s: tileset %q has vector tiles", name, lname)
+		}
+		layers = append(layers, raster.Layer{Source: &layersource{s, lname}, Opacity: opacity})
+	}
+	if s.addsource(name, "composite of "+spec[n+1:], raster.NewComposite(name, layers)) == nil {
+		return fmt.Errorf("composite %s: tileset already exists", name)
+	}
+	return nil
+}
+
+// layersource is a layer of a composite tileset. The tileset is looked up
+// by name on each use, so composites follow tilesets removed, added or
+// replaced in watched directories. Missing layers and layers that
+// became vector tilesets have no tiles.
+type layersource struct {
+	s    *server
+	name string
+}
+
+func (l *layersource) source() mbtiles.TileSource {
+	if ts := l.s.get(l.name); ts != nil && !ts.isvector() {
+		return ts.mbt
+	}
+	return nil
+}
+
+func (l *layersource) GetTile(z, x, y int) ([]byte, error) {
+	return l.GetTileContext(context.Background(), z, x, y)
+}
+
+func (l *layersource) GetTileContext(ctx context.Context, z, x, y int) ([]byte, error) {
+	if src := l.source(); src != nil {
+		return mbtiles.GetTileContext(ctx, src, z, x, y)
+	}
+	return nil, mbtiles.ErrTileNotFound
+}
+
+func (l *layersource) GetGridData(z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+func (l *layersource) GetGridContext(ctx context.Context, z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+func (l *layersource) Metadata() *mbtiles.Metadata {
+	if src := l.source(); src != nil {
+		return src.Metadata()
+	}
+	return mbtiles.ParseMetadata(nil)
+}
+
+func (l *layersource) ModTime() time.Time {
+	if src := l.source(); src != nil {
+		return src.ModTime()
+	}
+	return time.Time{}
+}
@@ -10,16 +10,16 @@ import (
 type leafletparams struct {
 	M       *mbtiles.Metadata
 	Leaflet string
+	Ext     string
+	MaxZoom int
 }
 
-func enable_leaflet(mbt *mbtiles.Map, libpath string) error {
-	leaflettmpl, err := template.New("leaflettmpl").Parse(leaflettext)
-	if err != nil {
-		return err
-	}
+// enable_leaflet_lib serves libpath at /leaflet/ if it is a local path,
+// and returns the url of leaflet to be used in html pages
+func enable_leaflet_lib(libpath string) (string, error) {
 	liburl, err := url.Parse(libpath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if !liburl.IsAbs() {
 		// url is local path, serve contents at /leaflet/
@@ -28,10 +28,18 @@ func enable_leaflet(mbt *mbtiles.Map, libpath string) error {
 		http.Handle(libpath, http.StripPrefix(libpath,
 			http.FileServer(http.Dir(source))))
 	}
-	http.Handle("/", http.HandlerFunc(
+	return libpath, nil
+}
+
+func enable_leaflet(mux *http.ServeMux, ts *tileset, libpath string) error {
+	leaflettmpl, err := template.New("leaflettmpl").Parse(leaflettext)
+	if err != nil {
+		return err
+	}
+	mux.Handle("/", http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
-			metadata := mbt.Metadata()
-			err := leaflettmpl.Execute(w, leafletparams{metadata, libpath})
+			metadata := ts.mbt.Metadata()
+			err := leaflettmpl.Execute(w, leafletparams{metadata, libpath, ts.ext(), maxzoom(metadata)})
 			if err != nil {
 				http.Error(w, "template error: "+err.Error(), 500)
 			}
@@ -52,13 +60,13 @@ var leaflettext = `<html>
 			var map;
 			function initMap() {
 				map = new L.Map('map', {
-					center: new L.LatLng({{.M.Center.Lon}}, {{.M.Center.Lat}}),
+					center: new L.LatLng({{.M.Center.Lat}}, {{.M.Center.Lon}}),
 					zoom: {{.M.Center.Zoom}}
 				});
-				var tmpl = './tiles/{z}/{x}/{y}.png';
+				var tmpl = './tiles/{z}/{x}/{y}.{{.Ext}}';
 				var layer = new L.TileLayer(tmpl, {
 					minZoom: {{.M.MinZoom}},
-					maxZoom: {{.M.MaxZoom}}
+					maxZoom: {{.MaxZoom}}
 				});
 				var scale = new L.Control.Scale();
 				map.addLayer(layer);
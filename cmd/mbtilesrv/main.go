@@ -1,15 +1,16 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/tile"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/fcgi"
-	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -18,7 +19,7 @@ import (
 
 func chk_fatal(err error) {
 	if err != nil {
-		log.Fatal()
+		log.Fatal(err)
 	}
 }
 
@@ -33,11 +34,17 @@ var modestmaps = flag.Bool("modestmaps", false, "serve modestmaps")
 var leaflet = flag.String("leaflet", "", "serve leaflet with path to its dist folder")
 var wax = flag.Bool("wax", false, "serve wax")
 var serve = flag.String("serve", "", "additional paths to serve")
+var tilejson1 = flag.Bool("tilejson1", false, "serve legacy TileJSON 1.0.0 with relative URLs at map.json")
+var overzoom = flag.Int("overzoom", 0, "synthesize tiles from ancestors up to this many zoom levels above the maximum zoom")
+var trustproxy = flag.Bool("trustproxy", false, "use X-Forwarded-Proto and X-Forwarded-Host headers in absolute URLs")
+var overzoomcache = flag.Int("overzoomcache", 1024, "number of synthesized tiles cached per tileset")
 
-var tile_content_type string
-var mbt *mbtiles.Map
+var scaninterval = flag.Duration("scan", 5*time.Second, "interval to check directories for added or removed files")
 
-const tilesize = 256
+// leafletpath is the url of leaflet used in html pages
+var leafletpath string
+
+const tilesize = tile.Size
 
 func main() {
 	flag.Parse()
@@ -45,50 +52,29 @@ func main() {
 		log.Fatal("options -modestmaps and -leaflet are mutually exclusive")
 	}
 
-	if len(flag.Args()) != 1 {
-		log.Fatal("exactly one .mbtiles file must be specified")
+	if len(flag.Args()) == 0 {
+		log.Fatal("at least one .mbtiles or .pmtiles file or directory must be specified")
 	}
 
-	var err error
-	mbt, err = mbtiles.Open(flag.Arg(0))
-	chk_fatal(err)
-	defer mbt.Close()
-	mbt.SetAutoReload(true)
-
-	enable_bgimg()
-
-	servezxy("/tiles/", tiler)
-	servezxy("/grids/", gridder)
-	servefn("/map.json", "", func(req *http.Request) (io.ReadSeeker, time.Time, error) {
-		return TileJson(mbt, "")
-	})
-	servefn("/map.jsonp", "text/javascript", func(req *http.Request) (io.ReadSeeker, time.Time, error) {
-		return TileJson(mbt, req.URL.Query().Get("callback"))
-	})
+	if *leaflet != "" {
+		var err error
+		leafletpath, err = enable_leaflet_lib(*leaflet)
+		chk_fatal(err)
+	}
 
-	if *modestmaps {
-		enable_modestmaps(mbt)
-	} else if *leaflet != "" {
-		enable_leaflet(mbt, *leaflet)
-	} else if *wax {
-		enable_cache("/lib/")
-		http.Handle("/", http.HandlerFunc(
-			func(w http.ResponseWriter, req *http.Request) {
-				fn := "index.html"
-				rs, err := os.Open(fn)
-				if err == nil {
-					http.ServeContent(w, req, fn, time.Time{}, rs)
-				} else {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
-			}))
-	} else {
-		tmpl := &MapboxjsTemplate{mbt: mbt, debug: *debug}
-		http.Handle("/", http.HandlerFunc(
-			func(w http.ResponseWriter, req *http.Request) {
-				tmpl.Execute(w, req)
-			}))
+	srv := newserver()
+	for _, arg := range flag.Args() {
+		chk_fatal(srv.add(arg, *scaninterval))
+	}
+	for _, spec := range composites {
+		chk_fatal(srv.addcomposite(spec))
 	}
+	if len(flag.Args()) == 1 && len(srv.sets) == 1 && len(srv.dirs) == 0 {
+		for _, ts := range srv.sets {
+			srv.root = ts
+		}
+	}
+	http.Handle("/", srv)
 
 	if *serve != "" {
 		for _, entry := range strings.Split(*serve, ",") {
@@ -117,6 +103,7 @@ func main() {
 		}
 		h = stripPrefix(pfx, h)
 	}
+	var err error
 	if *dofcgi {
 		l, err := net.Listen("tcp", *addr)
 		if err == nil {
@@ -144,36 +131,13 @@ func stripPrefix(prefix string, h http.Handler) http.Handler {
 	})
 }
 
-func tiler(w http.ResponseWriter, req *http.Request, z, x, y int) error {
-	blob, err := mbt.GetTile(z, x, y)
-	if err == mbtiles.ErrTileNotFound && *markmissing {
-		log.Println("notile", z, x, y)
-		blob, err = nosuchtile("no such tile", z, x, y), nil
-	}
-	if err == nil {
-		http.ServeContent(w, req, "tile.png", mbt.Mtime, bytes.NewReader(blob))
-	}
-	return err
-}
-
-func gridder(w http.ResponseWriter, req *http.Request, z, x, y int) error {
-	if *gridderlog {
-		log.Println("gridder", req.URL)
-	}
-	blob, err := mbt.GetGridData(z, x, y, req.URL.Query().Get("callback"))
-	if err == nil {
-		http.ServeContent(w, req, "grid.js", mbt.Mtime, bytes.NewReader(blob))
-	}
-	return err
-}
-
 func zxynotfound(err error, w http.ResponseWriter, req *http.Request) {
 	log.Println(req.URL.Path, "not found:", err)
 	http.Error(w, req.URL.Path+" not found", http.StatusNotFound)
 }
 
-func servezxy(prefix string, f func(w http.ResponseWriter, req *http.Request, z, x, y int) error) {
-	http.Handle(prefix, http.StripPrefix(prefix, http.HandlerFunc(
+func servezxy(mux *http.ServeMux, prefix string, f func(w http.ResponseWriter, req *http.Request, z, x, y int) error) {
+	mux.Handle(prefix, http.StripPrefix(prefix, http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
 			err := mbtiles.ErrTileNotFound
 			parts := strings.Split(req.URL.Path, "/")
@@ -192,14 +156,13 @@ func servezxy(prefix string, f func(w http.ResponseWriter, req *http.Request, z,
 				}
 				z, x, y := args[0], args[1], args[2]
 				// Flip Y coordinate because MBTiles files are TMS
-				y = (1 << uint(z)) - 1 - y
+				y = tile.FlipY(z, y)
 				err = f(w, req, z, x, y)
 				if err == nil {
 					return
 				}
-				if err != mbtiles.ErrTileNotFound {
-					log.Println(z, x, y, err)
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+				if code := tilestatus(err); code != http.StatusNotFound {
+					zxyerror(err, code, w, req)
 					return
 				}
 			}
@@ -207,8 +170,37 @@ func servezxy(prefix string, f func(w http.ResponseWriter, req *http.Request, z,
 		})))
 }
 
-func servefn(pth string, ctyp string, f func(req *http.Request) (io.ReadSeeker, time.Time, error)) {
-	http.Handle(pth, http.HandlerFunc(
+// tilestatus returns the HTTP status code for err returned by a tile lookup
+func tilestatus(err error) int {
+	switch {
+	case errors.Is(err, mbtiles.ErrOutOfRange), err == mbtiles.ErrCallback:
+		return http.StatusBadRequest
+	case errors.Is(err, mbtiles.ErrTileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, mbtiles.ErrClosed):
+		// tileset removed or reloaded while serving the request
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.Canceled):
+		return 0
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func zxyerror(err error, code int, w http.ResponseWriter, req *http.Request) {
+	if code == 0 {
+		// client went away
+		return
+	}
+	if code >= 500 {
+		log.Println(req.URL.Path, err)
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func servefn(mux *http.ServeMux, pth string, ctyp string, f func(req *http.Request) (io.ReadSeeker, time.Time, error)) {
+	mux.Handle(pth, http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
 			rs, t, err := f(req)
 			if ctyp != "" {
@@ -216,6 +208,8 @@ func servefn(pth string, ctyp string, f func(req *http.Request) (io.ReadSeeker,
 			}
 			if err == nil {
 				http.ServeContent(w, req, pth, t, rs)
+			} else if err == mbtiles.ErrCallback {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 			} else {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
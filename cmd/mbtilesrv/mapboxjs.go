@@ -10,7 +10,7 @@ import (
 )
 
 type MapboxjsTemplate struct {
-	mbt         *mbtiles.Map
+	mbt         mbtiles.TileSource
 	debug       bool
 	cachedtitle string
 	data        []byte
@@ -9,15 +9,15 @@ import (
 	"net/http"
 )
 
-func enable_modestmaps(mbt *mbtiles.Map) error {
+func enable_modestmaps(mux *http.ServeMux, ts *tileset) error {
 	mmtmpl, err := template.New("mmtmpl").Parse(mmtext)
 	if err != nil {
 		return err
 	}
-	http.Handle("/", http.HandlerFunc(
+	mux.Handle("/", http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
-			metadata := mbt.Metadata()
-			err = mmtmpl.Execute(w, metadata)
+			md := ts.mbt.Metadata()
+			err = mmtmpl.Execute(w, mmparams{md, ts.ext(), maxzoom(md)})
 			if err != nil {
 				http.Error(w, "template error: "+err.Error(), 500)
 			}
@@ -25,6 +25,12 @@ func enable_modestmaps(mbt *mbtiles.Map) error {
 	return nil
 }
 
+type mmparams struct {
+	*mbtiles.Metadata
+	Ext     string
+	MaxZoom int
+}
+
 var mmtext = `<html>
 	<head>
 		<title>{{.Name}}</title>
@@ -35,11 +41,11 @@ var mmtext = `<html>
 			var map;
 			function initMap() {
 				var layer = new MM.Layer(new MM.MapProvider(function(coord) {
-					var img = parseInt(coord.zoom) + '/' + parseInt(coord.column) + '/'+ parseInt(coord.row) + '.jpg';
-					return '/tiles/' + img;
+					var img = parseInt(coord.zoom) + '/' + parseInt(coord.column) + '/'+ parseInt(coord.row) + '.{{.Ext}}';
+					return 'tiles/' + img;
 				}))
 				map = new MM.Map('map', layer);
-				map.setCenterZoom(new MM.Location({{.Center.Lon}}, {{.Center.Lat}}), {{.Center.Zoom}});
+				map.setCenterZoom(new MM.Location({{.Center.Lat}}, {{.Center.Lon}}), {{.Center.Zoom}});
 				map.setZoomRange({{.MinZoom}},{{.MaxZoom}});
 			}
 		</script>
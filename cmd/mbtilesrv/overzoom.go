@@ -0,0 +1,159 @@
+package main
+
+// synthesis of tiles above the maximum zoom level from ancestor tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/mvt"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxoverzoomdz is the largest zoom difference between a synthesized tile
+// and its ancestor, at which a pixel of the ancestor covers a whole tile.
+const maxoverzoomdz = 8
+
+// maxzoom returns the highest zoom level served for md.
+func maxzoom(md *mbtiles.Metadata) int {
+	return md.MaxZoom + *overzoom
+}
+
+// overzoomed returns the tile z, x, y in TMS coordinates synthesized from
+// its nearest existing ancestor, if z is above the maximum zoom level
+// of the tileset by at most -overzoom levels.
+func (ts *tileset) overzoomed(ctx context.Context, z, x, y int) ([]byte, error) {
+	md := ts.mbt.Metadata()
+	if z <= md.MaxZoom || z > maxzoom(md) {
+		return nil, mbtiles.ErrTileNotFound
+	}
+	mtime := ts.mbt.ModTime()
+	t := tile.Tile{Z: z, X: x, Y: tile.FlipY(z, y)}
+	if blob, ok := ts.ozcache.get(t, mtime); ok {
+		if blob == nil {
+			return nil, mbtiles.ErrTileNotFound
+		}
+		return blob, nil
+	}
+	for az := md.MaxZoom; az >= 0 && z-az <= maxoverzoomdz; az-- {
+		a := t.Ancestor(az)
+		blob, err := mbtiles.GetTileContext(ctx, ts.mbt, a.Z, a.X, tile.FlipY(a.Z, a.Y))
+		if tilestatus(err) == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		dz := uint(z - az)
+		blob, err = overzoomtile(ts.format(), blob, int(dz), t.X-a.X<<dz, t.Y-a.Y<<dz)
+		if err != nil {
+			return nil, err
+		}
+		ts.ozcache.put(t, mtime, blob)
+		return blob, nil
+	}
+	ts.ozcache.put(t, mtime, nil)
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// overzoomtile returns the part of the raster or vector tile blob covering
+// its descendant dz levels deeper at column x and row y in XYZ order.
+// Blobs of unknown format are assumed to have format deflt.
+func overzoomtile(deflt mbtiles.TileFormat, blob []byte, dz, x, y int) ([]byte, error) {
+	f, c := mbtiles.DetectFormat(blob)
+	if f == mbtiles.UnknownFormat {
+		f = deflt
+	}
+	if f != mbtiles.PBF {
+		return raster.Overzoom(blob, dz, x, y)
+	}
+	data, err := decompress(blob, c)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = mvt.Overzoom(data, dz, x, y); err != nil {
+		return nil, err
+	}
+	if c == mbtiles.Uncompressed {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tilecache is a LRU cache of synthesized tiles.
+// A nil blob records a tile that could not be synthesized.
+type tilecache struct {
+	mtx   sync.Mutex
+	max   int
+	mtime time.Time // modification time of the tileset
+	items map[tile.Tile]*list.Element
+	lru   *list.List
+}
+
+type tilecacheentry struct {
+	t    tile.Tile
+	blob []byte
+}
+
+func newtilecache(max int) *tilecache {
+	return &tilecache{
+		max:   max,
+		items: make(map[tile.Tile]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// sync drops the cached tiles if the tileset was modified.
+func (c *tilecache) sync(mtime time.Time) {
+	if !mtime.Equal(c.mtime) {
+		c.mtime = mtime
+		c.items = make(map[tile.Tile]*list.Element)
+		c.lru.Init()
+	}
+}
+
+func (c *tilecache) get(t tile.Tile, mtime time.Time) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.sync(mtime)
+	e, ok := c.items[t]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*tilecacheentry).blob, true
+}
+
+func (c *tilecache) put(t tile.Tile, mtime time.Time, blob []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.sync(mtime)
+	if c.max <= 0 {
+		return
+	}
+	if e, ok := c.items[t]; ok {
+		e.Value.(*tilecacheentry).blob = blob
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.items[t] = c.lru.PushFront(&tilecacheentry{t, blob})
+	for c.lru.Len() > c.max {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.items, e.Value.(*tilecacheentry).t)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+// feature lookup by location using UTFGrids
+
+import (
+	"encoding/json"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type queryresult struct {
+	Lon   float64         `json:"lon"`
+	Lat   float64         `json:"lat"`
+	Tile  [3]int          `json:"tile"`  // z, x, y in XYZ order
+	Pixel [2]int          `json:"pixel"` // within the tile
+	Key   *string         `json:"key"`   // null if there is no feature
+	Data  json.RawMessage `json:"data"`
+}
+
+// query serves the grid key and data of the feature at
+// the location given by the lon, lat and z query parameters.
+// The highest zoom level of the tileset is used if z is missing.
+func (ts *tileset) query(w http.ResponseWriter, req *http.Request) {
+	src, ok := ts.mbt.(mbtiles.GridSource)
+	if !ok {
+		http.Error(w, "tileset has no grids", http.StatusNotFound)
+		return
+	}
+	q := req.URL.Query()
+	lon, err1 := strconv.ParseFloat(q.Get("lon"), 64)
+	lat, err2 := strconv.ParseFloat(q.Get("lat"), 64)
+	if err1 != nil || err2 != nil || lon < -180 || lon > 180 || lat < -tile.MaxLat || lat > tile.MaxLat {
+		http.Error(w, "lon and lat must be valid coordinates", http.StatusBadRequest)
+		return
+	}
+	z := src.Metadata().MaxZoom
+	if s := q.Get("z"); s != "" {
+		var err error
+		if z, err = strconv.Atoi(s); err != nil || z < 0 || z > tile.MaxZoom {
+			http.Error(w, "invalid zoom level", http.StatusBadRequest)
+			return
+		}
+	}
+
+	t, px, py := tile.PixelFromLonLat(lon, lat, z)
+	res := &queryresult{
+		Lon:   lon,
+		Lat:   lat,
+		Tile:  [3]int{t.Z, t.X, t.Y},
+		Pixel: [2]int{px, py},
+	}
+
+	g, err := src.GetGrid(t.Z, t.X, tile.FlipY(t.Z, t.Y))
+	if err != nil {
+		code := tilestatus(err)
+		if code >= 500 {
+			log.Println(req.URL, err)
+		}
+		if code != 0 {
+			http.Error(w, err.Error(), code)
+		}
+		return
+	}
+	if key, data := g.DataAt(px, py, tile.Size); key != "" {
+		res.Key, res.Data = &key, data
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Println("query:", err)
+	}
+}
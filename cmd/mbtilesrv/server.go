@@ -0,0 +1,271 @@
+package main
+
+// serving multiple tilesets, each under /{name}/
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const mbtilesext = ".mbtiles"
+
+type server struct {
+	mtx  sync.RWMutex
+	sets map[string]*tileset
+
+	scanmtx sync.Mutex          // serializes directory scans
+	dirs    map[string][]string // directory -> names of tilesets loaded from it
+
+	// root is served at the root path if a single file is specified,
+	// as earlier versions did
+	root *tileset
+}
+
+func newserver() *server {
+	return &server{
+		sets: make(map[string]*tileset),
+		dirs: make(map[string][]string),
+	}
+}
+
+// tilesetname returns the name of the tileset for file fn
+func tilesetname(fn string) string {
+	return strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+}
+
+// addfile opens and mounts the tileset in fn
+func (s *server) addfile(fn string) (*tileset, error) {
+	name := tilesetname(fn)
+	if s.get(name) != nil {
+		log.Printf("%s: tileset %q already exists, ignored\n", fn, name)
+		return nil, nil
+	}
+	mbt, err := opensource(fn)
+	if err != nil {
+		return nil, err
+	}
+	return s.addsource(name, fn, mbt), nil
+}
+
+// addsource mounts mbt at /{name}/. It returns nil and closes
+// mbt if a tileset with the same name exists. Origin is logged.
+func (s *server) addsource(name, origin string, mbt mbtiles.TileSource) *tileset {
+	ts := newtileset(name, mbt)
+	s.mtx.Lock()
+	_, dup := s.sets[name]
+	if !dup {
+		s.sets[name] = ts
+	}
+	s.mtx.Unlock()
+	if dup {
+		ts.close()
+		return nil
+	}
+	log.Printf("serving: /%s/ -> %s\n", name, origin)
+	return ts
+}
+
+func (s *server) remove(name string) {
+	s.mtx.Lock()
+	ts := s.sets[name]
+	delete(s.sets, name)
+	s.mtx.Unlock()
+	if ts != nil {
+		ts.close()
+		log.Printf("removed: /%s/\n", name)
+	}
+}
+
+// adddir mounts the tilesets found in dir, and watches it
+// for added or removed files
+func (s *server) adddir(dir string, interval time.Duration) error {
+	if err := s.scandir(dir); err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		for range t.C {
+			if err := s.scandir(dir); err != nil {
+				log.Println("scan:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *server) scandir(dir string) error {
+	s.scanmtx.Lock()
+	defer s.scanmtx.Unlock()
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool)
+	for _, fi := range fis {
+		if !fi.IsDir() && istileset(fi.Name()) {
+			present[tilesetname(fi.Name())] = true
+		}
+	}
+	var keep []string
+	for _, name := range s.dirs[dir] {
+		if present[name] {
+			keep = append(keep, name)
+			delete(present, name)
+		} else {
+			s.remove(name)
+		}
+	}
+	for _, fi := range fis {
+		if !present[tilesetname(fi.Name())] {
+			continue
+		}
+		ts, err := s.addfile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			log.Println(err)
+		}
+		if ts != nil {
+			keep = append(keep, ts.name)
+		}
+	}
+	s.dirs[dir] = keep
+	return nil
+}
+
+func (s *server) add(pth string, interval time.Duration) error {
+	fi, err := os.Stat(pth)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return s.adddir(pth, interval)
+	}
+	_, err = s.addfile(pth)
+	return err
+}
+
+func (s *server) get(name string) *tileset {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.sets[name]
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := strings.TrimPrefix(req.URL.Path, "/")
+	name := p
+	if n := strings.IndexByte(p, '/'); n != -1 {
+		name = p[:n]
+	}
+	if ts := s.get(name); ts != nil {
+		if name == p {
+			// relative redirect keeps any path prefix
+			w.Header().Set("Location", name+"/")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		http.StripPrefix("/"+name, ts.mux).ServeHTTP(w, req)
+		return
+	}
+	switch {
+	case p == "index.json":
+		s.serveindexjson(w, req)
+	case s.root != nil:
+		s.root.mux.ServeHTTP(w, req)
+	case p == "":
+		s.serveindex(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+type indexentry struct {
+	Name        string    `json:"name"`
+	Url         string    `json:"url"`
+	TileJson    string    `json:"tilejson"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Attribution string    `json:"attribution,omitempty"`
+	Format      string    `json:"format"`
+	MinZoom     int       `json:"minzoom"`
+	MaxZoom     int       `json:"maxzoom"`
+	Bounds      []float64 `json:"bounds"`
+	Center      []float64 `json:"center"`
+	Mtime       time.Time `json:"mtime"`
+}
+
+func (s *server) index() []indexentry {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	v := make([]indexentry, 0, len(s.sets))
+	for name, ts := range s.sets {
+		md := ts.mbt.Metadata()
+		v = append(v, indexentry{
+			Name:        name,
+			Url:         "./" + name + "/",
+			TileJson:    "./" + name + "/map.json",
+			Title:       md.Name,
+			Description: md.Description,
+			Attribution: md.Attribution,
+			Format:      ts.ext(),
+			MinZoom:     md.MinZoom,
+			MaxZoom:     md.MaxZoom,
+			Bounds:      []float64{md.Bounds.W, md.Bounds.S, md.Bounds.E, md.Bounds.N},
+			Center:      []float64{md.Center.Lon, md.Center.Lat, md.Center.Zoom},
+			Mtime:       ts.mbt.ModTime(),
+		})
+	}
+	sort.Slice(v, func(i, j int) bool { return v[i].Name < v[j].Name })
+	return v
+}
+
+func (s *server) serveindexjson(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.index()); err != nil {
+		log.Println("index:", err)
+	}
+}
+
+var indextmpl = template.Must(template.New("index").Parse(`<html>
+	<head>
+		<title>MBTileSrv</title>
+		<style>
+			body { font-family: sans-serif; }
+			td, th { padding: 2px 8px; text-align: left; }
+		</style>
+	</head>
+<body>
+<table>
+	<tr><th>Name</th><th>Title</th><th>Format</th><th>Zoom</th><th>Bounds</th><th>Description</th></tr>
+	{{range .}}<tr>
+		<td><a href="{{.Url}}">{{.Name}}</a> (<a href="{{.TileJson}}">json</a>)</td>
+		<td>{{.Title}}</td>
+		<td>{{.Format}}</td>
+		<td>{{.MinZoom}}-{{.MaxZoom}}</td>
+		<td>{{range $i, $v := .Bounds}}{{if $i}}, {{end}}{{$v}}{{end}}</td>
+		<td>{{.Description}}</td>
+	</tr>
+	{{end}}
+</table>
+</body>
+</html>
+`))
+
+func (s *server) serveindex(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	if err := indextmpl.Execute(&buf, s.index()); err != nil {
+		http.Error(w, "template error: "+err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/pmtiles"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+const pmtilesext = ".pmtiles"
+
+// istileset reports if fn is a file name of a supported tileset
+func istileset(fn string) bool {
+	ext := filepath.Ext(fn)
+	return strings.EqualFold(ext, mbtilesext) || strings.EqualFold(ext, pmtilesext)
+}
+
+// opensource opens fn as a PMTiles archive or MBTiles file
+// depending on its extension. MBTiles files are reloaded
+// automatically when they change.
+func opensource(fn string) (mbtiles.TileSource, error) {
+	if strings.EqualFold(filepath.Ext(fn), pmtilesext) {
+		return pmtiles.Open(fn)
+	}
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	mbt.OnReload(func(ev mbtiles.ReloadEvent) {
+		if ev.Err != nil {
+			log.Printf("%s: reload: %v\n", ev.Filename, ev.Err)
+		} else {
+			log.Printf("%s: reloaded, modified %v\n", ev.Filename, ev.Mtime)
+		}
+	})
+	mbt.SetAutoReload(true)
+	return mbt, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+// static map images
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxstaticsize is the largest width and height of static map images
+const maxstaticsize = 2048
+
+// static serves static map images at
+//
+//	/static/{lon},{lat},{zoom}/{width}x{height}.png
+//	/static/[{w},{s},{e},{n}]/{width}x{height}.png
+//
+// Markers are given with marker=lon,lat[,color] query parameters,
+// and a GeoJSON overlay with the geojson parameter.
+func (ts *tileset) static(w http.ResponseWriter, req *http.Request) {
+	if ts.isvector() {
+		http.Error(w, "static maps of vector tilesets are not supported", http.StatusBadRequest)
+		return
+	}
+	view, err := parsestatic(req.URL.Path, ts.mbt.Metadata().MaxZoom)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opt := new(raster.RenderOptions)
+	q := req.URL.Query()
+	for _, s := range q["marker"] {
+		m, err := parsemarker(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opt.Markers = append(opt.Markers, m)
+	}
+	if s := q.Get("geojson"); s != "" {
+		if opt.Overlay, err = raster.ParseGeoJSON([]byte(s)); err != nil {
+			http.Error(w, "geojson: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	im, err := raster.Render(req.Context(), ts.mbt, view, opt)
+	if err != nil {
+		code := tilestatus(err)
+		if code >= 500 {
+			log.Println(req.URL, err)
+		}
+		if code != 0 {
+			http.Error(w, err.Error(), code)
+		}
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeContent(w, req, "static.png", ts.mbt.ModTime(), bytes.NewReader(buf.Bytes()))
+}
+
+// parsestatic returns the view from a static map path,
+// bounds are shown at zoom levels up to maxz.
+func parsestatic(pth string, maxz int) (raster.View, error) {
+	var v raster.View
+	dir, file := path.Split(strings.TrimPrefix(pth, "/static/"))
+	dir = strings.TrimSuffix(dir, "/")
+	if !strings.HasSuffix(file, ".png") {
+		return v, fmt.Errorf("static map must be a .png image")
+	}
+	var width, height int
+	if n, err := fmt.Sscanf(strings.TrimSuffix(file, ".png"), "%dx%d", &width, &height); n != 2 || err != nil ||
+		width <= 0 || height <= 0 || width > maxstaticsize || height > maxstaticsize {
+		return v, fmt.Errorf("image size must be {width}x{height} up to %d pixels", maxstaticsize)
+	}
+	if strings.HasPrefix(dir, "[") && strings.HasSuffix(dir, "]") {
+		f, err := parsefloats(dir[1:len(dir)-1], 4)
+		if err != nil || f[1] > f[3] {
+			return v, fmt.Errorf("bounds must be [w,s,e,n]")
+		}
+		if err = checklonlat(f[0], f[1]); err == nil {
+			err = checklonlat(f[2], f[3])
+		}
+		if err != nil {
+			return v, err
+		}
+		return raster.FitBounds(tile.Bounds{W: f[0], S: f[1], E: f[2], N: f[3]}, width, height, maxz), nil
+	}
+	f, err := parsefloats(dir, 3)
+	if err != nil || f[2] != float64(int(f[2])) || f[2] < 0 || f[2] > tile.MaxZoom {
+		return v, fmt.Errorf("center must be {lon},{lat},{zoom} with integer zoom")
+	}
+	if err = checklonlat(f[0], f[1]); err != nil {
+		return v, err
+	}
+	return raster.View{Lon: f[0], Lat: f[1], Zoom: int(f[2]), Width: width, Height: height}, nil
+}
+
+// parsemarker parses a marker in lon,lat[,color] format.
+func parsemarker(s string) (raster.Marker, error) {
+	var m raster.Marker
+	v := strings.Split(s, ",")
+	if len(v) == 3 {
+		c, err := raster.ParseColor(v[2])
+		if err != nil {
+			return m, err
+		}
+		m.Color = c
+		v = v[:2]
+	}
+	f, err := parsefloats(strings.Join(v, ","), 2)
+	if err != nil {
+		return m, fmt.Errorf("marker must be lon,lat[,color]")
+	}
+	if err = checklonlat(f[0], f[1]); err != nil {
+		return m, err
+	}
+	m.Lon, m.Lat = f[0], f[1]
+	return m, nil
+}
+
+// checklonlat checks that lon, lat is within the Web Mercator range.
+func checklonlat(lon, lat float64) error {
+	if math.Abs(lon) > 180 || math.Abs(lat) > tile.MaxLat {
+		return fmt.Errorf("coordinates %g,%g out of range", lon, lat)
+	}
+	return nil
+}
+
+// parsefloats parses n comma separated finite numbers.
+func parsefloats(s string, n int) ([]float64, error) {
+	v := strings.Split(s, ",")
+	if len(v) != n {
+		return nil, fmt.Errorf("expected %d numbers", n)
+	}
+	f := make([]float64, n)
+	for i, x := range v {
+		var err error
+		if f[i], err = strconv.ParseFloat(strings.TrimSpace(x), 64); err != nil {
+			return nil, err
+		}
+		if math.IsNaN(f[i]) || math.IsInf(f[i], 0) {
+			return nil, fmt.Errorf("invalid number %q", x)
+		}
+	}
+	return f, nil
+}
@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"github.com/tajtiattila/go-mbtiles/mbtiles"
 	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 )
 
@@ -19,22 +23,33 @@ type MapData struct {
 	Grids    []string  `json:"grids"`
 	Template string    `json:"template"`
 	Legend   string    `json:"legend"`
+
+	Format       string                `json:"format,omitempty"`
+	VectorLayers []mbtiles.VectorLayer `json:"vector_layers,omitempty"`
 }
 
-func TileJson(mbt *mbtiles.Map, callback string) (io.ReadSeeker, time.Time, error) {
+// TileJson returns the legacy TileJSON 1.0.0 document of mbt
+// with relative URLs using the tile extension ext,
+// in JSONP format if callback is not empty.
+func TileJson(mbt mbtiles.TileSource, ext, callback string) (io.ReadSeeker, time.Time, error) {
+	if err := mbtiles.CheckCallback(callback); err != nil {
+		return nil, time.Time{}, err
+	}
 	md := mbt.Metadata()
 
 	mapdata := &MapData{
 		"1.0.0",
 		md.Name,
 		md.MinZoom,
-		md.MaxZoom,
+		maxzoom(md),
 		[]float64{md.Bounds.W, md.Bounds.S, md.Bounds.E, md.Bounds.N},
-		[]float64{md.Center.Lat, md.Center.Lon, md.Center.Zoom},
-		[]string{"./tiles/{z}/{x}/{y}.png"},
+		[]float64{md.Center.Lon, md.Center.Lat, md.Center.Zoom},
+		[]string{"./tiles/{z}/{x}/{y}." + ext},
 		[]string{"./grids/{z}/{x}/{y}.json"},
 		md.Template,
 		md.Legend,
+		md.Format,
+		md.VectorLayers,
 	}
 
 	var buf bytes.Buffer
@@ -48,5 +63,58 @@ func TileJson(mbt *mbtiles.Map, callback string) (io.ReadSeeker, time.Time, erro
 		buf.WriteString(");")
 	}
 
-	return bytes.NewReader(buf.Bytes()), mbt.Mtime, nil
+	return bytes.NewReader(buf.Bytes()), mbt.ModTime(), nil
+}
+
+// TileJson3 returns the TileJSON 3.0.0 document of mbt
+// with absolute URLs for req using the tile extension ext.
+func TileJson3(mbt mbtiles.TileSource, ext string, req *http.Request) (io.ReadSeeker, time.Time, error) {
+	base := baseurl(req)
+	tiles := []string{base + "tiles/{z}/{x}/{y}." + ext}
+	var grids []string
+	if g, ok := mbt.(interface{ HasGrids() bool }); ok && g.HasGrids() {
+		grids = []string{base + "grids/{z}/{x}/{y}.json"}
+	}
+	md := mbt.Metadata()
+	tj := md.TileJSON(tiles, grids)
+	if *overzoom > 0 {
+		tj.MaxZoom = maxzoom(md)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(tj); err != nil {
+		return nil, time.Time{}, err
+	}
+	return bytes.NewReader(buf.Bytes()), mbt.ModTime(), nil
+}
+
+// baseurl returns the absolute URL of the directory of
+// the resource requested by req. The original request URI
+// is used, so it includes the path prefix and tileset name.
+// Forwarded scheme and host headers are used only with -trustproxy,
+// otherwise any client could inject hosts into the URLs.
+func baseurl(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+	if *trustproxy {
+		if p := req.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+			scheme = p
+		}
+		if h := req.Header.Get("X-Forwarded-Host"); h != "" {
+			host = h
+		}
+	}
+	p := req.RequestURI
+	if u, err := url.ParseRequestURI(p); err == nil {
+		p = u.Path
+	}
+	p = path.Dir(p)
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	u := url.URL{Scheme: scheme, Host: host, Path: p}
+	return u.String()
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tileset serves a single tile source
+type tileset struct {
+	name string
+	mbt  mbtiles.TileSource
+	mux  *http.ServeMux
+
+	ozcache *tilecache // overzoomed tiles
+
+	fmtmtx   sync.Mutex
+	fmtmtime time.Time // modification time of mbt when format was detected
+	tilefmt  mbtiles.TileFormat
+}
+
+// format returns the tile format of the tileset. It is detected
+// once, and again after the tile source has been modified.
+func (ts *tileset) format() mbtiles.TileFormat {
+	mtime := ts.mbt.ModTime()
+	ts.fmtmtx.Lock()
+	defer ts.fmtmtx.Unlock()
+	if ts.tilefmt == mbtiles.UnknownFormat || !mtime.Equal(ts.fmtmtime) {
+		ts.tilefmt, ts.fmtmtime = tileformat(ts.mbt), mtime
+	}
+	return ts.tilefmt
+}
+
+func (ts *tileset) isvector() bool {
+	return ts.format() == mbtiles.PBF
+}
+
+// ext returns the file extension used in tile URLs
+func (ts *tileset) ext() string {
+	return ts.format().Ext()
+}
+
+func newtileset(name string, mbt mbtiles.TileSource) *tileset {
+	ts := &tileset{name: name, mbt: mbt, mux: http.NewServeMux(), ozcache: newtilecache(*overzoomcache)}
+	mux := ts.mux
+
+	enable_bgimg(mux)
+
+	servezxy(mux, "/tiles/", ts.tiler)
+	servezxy(mux, "/grids/", ts.gridder)
+	mux.HandleFunc("/query", ts.query)
+	mux.HandleFunc("/static/", ts.static)
+	servefn(mux, "/map.json", "application/json", func(req *http.Request) (io.ReadSeeker, time.Time, error) {
+		if *tilejson1 {
+			return TileJson(mbt, ts.ext(), "")
+		}
+		return TileJson3(mbt, ts.ext(), req)
+	})
+	servefn(mux, "/map.jsonp", "text/javascript", func(req *http.Request) (io.ReadSeeker, time.Time, error) {
+		return TileJson(mbt, ts.ext(), req.URL.Query().Get("callback"))
+	})
+
+	if *modestmaps {
+		enable_modestmaps(mux, ts)
+	} else if *leaflet != "" {
+		enable_leaflet(mux, ts, leafletpath)
+	} else if *wax {
+		enable_cache(mux, "/lib/")
+		mux.Handle("/", http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				fn := "index.html"
+				rs, err := os.Open(fn)
+				if err == nil {
+					defer rs.Close()
+					http.ServeContent(w, req, fn, time.Time{}, rs)
+				} else {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+			}))
+	} else {
+		tmpl := &MapboxjsTemplate{mbt: mbt, debug: *debug}
+		mux.Handle("/", http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				tmpl.Execute(w, req)
+			}))
+	}
+	return ts
+}
+
+func (ts *tileset) close() error {
+	if c, ok := ts.mbt.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (ts *tileset) tiler(w http.ResponseWriter, req *http.Request, z, x, y int) error {
+	mbt := ts.mbt
+	blob, err := mbtiles.GetTileContext(req.Context(), mbt, z, x, y)
+	if tilestatus(err) == http.StatusNotFound && *overzoom > 0 {
+		blob, err = ts.overzoomed(req.Context(), z, x, y)
+	}
+	if tilestatus(err) == http.StatusNotFound && *markmissing && !ts.isvector() {
+		log.Println("notile", ts.name, z, x, y)
+		blob, err = nosuchtile("no such tile", z, x, y), nil
+	}
+	if err != nil {
+		return err
+	}
+	f, c := mbtiles.DetectFormat(blob)
+	if f == mbtiles.UnknownFormat {
+		f = ts.format()
+	}
+	if f == mbtiles.PBF {
+		return servepbf(w, req, mbt.ModTime(), blob, c)
+	}
+	w.Header().Set("Content-Type", f.ContentType())
+	http.ServeContent(w, req, "tile."+f.Ext(), mbt.ModTime(), bytes.NewReader(blob))
+	return nil
+}
+
+func (ts *tileset) gridder(w http.ResponseWriter, req *http.Request, z, x, y int) error {
+	if *gridderlog {
+		log.Println("gridder", ts.name, req.URL)
+	}
+	blob, err := mbtiles.GetGridContext(req.Context(), ts.mbt, z, x, y, req.URL.Query().Get("callback"))
+	if err == nil {
+		http.ServeContent(w, req, "grid.js", ts.mbt.ModTime(), bytes.NewReader(blob))
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package main
+
+// serving of vector (Mapbox Vector Tile) tilesets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tileformat returns the format declared in the metadata,
+// or the format of an arbitrary tile if there is none.
+func tileformat(src mbtiles.TileSource) mbtiles.TileFormat {
+	if f := src.Metadata().TileFormat(); f != mbtiles.UnknownFormat {
+		return f
+	}
+	mbt, ok := src.(*mbtiles.Map)
+	if !ok {
+		return mbtiles.PNG
+	}
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	if it.Next() {
+		_, _, _, blob := it.Tile()
+		if f, _ := mbtiles.DetectFormat(blob); f != mbtiles.UnknownFormat {
+			return f
+		}
+	}
+	return mbtiles.PNG
+}
+
+// accepts reports if the client accepts the content coding enc
+func accepts(req *http.Request, enc string) bool {
+	for _, v := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		v = strings.TrimSpace(v)
+		if n := strings.IndexByte(v, ';'); n != -1 {
+			if strings.TrimSpace(v[n+1:]) == "q=0" {
+				continue
+			}
+			v = strings.TrimSpace(v[:n])
+		}
+		if v == enc || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// servepbf serves a vector tile. Tiles are usually stored
+// compressed, so blob is sent as is to clients accepting
+// its encoding, and decompressed for the rest.
+func servepbf(w http.ResponseWriter, req *http.Request, mtime time.Time, blob []byte, c mbtiles.Compression) error {
+	h := w.Header()
+	h.Set("Content-Type", mbtiles.PBF.ContentType())
+	h.Add("Vary", "Accept-Encoding")
+	var enc string
+	switch c {
+	case mbtiles.Gzip:
+		enc = "gzip"
+	case mbtiles.Zlib:
+		// the deflate content coding is zlib format
+		enc = "deflate"
+	}
+	if enc != "" {
+		if accepts(req, enc) {
+			h.Set("Content-Encoding", enc)
+		} else {
+			var err error
+			if blob, err = decompress(blob, c); err != nil {
+				return err
+			}
+		}
+	}
+	http.ServeContent(w, req, "tile.pbf", mtime, bytes.NewReader(blob))
+	return nil
+}
+
+func decompress(blob []byte, c mbtiles.Compression) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch c {
+	case mbtiles.Gzip:
+		r, err = gzip.NewReader(bytes.NewReader(blob))
+	case mbtiles.Zlib:
+		r, err = zlib.NewReader(bytes.NewReader(blob))
+	default:
+		return blob, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
@@ -1,15 +1,129 @@
 package mbtiles
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
 )
 
+// ReloadInterval is the interval of checks for modifications
+// when auto reload is enabled.
+var ReloadInterval = time.Second
+
+// ReloadEvent reports a reload of a Map.
+type ReloadEvent struct {
+	Filename string
+	Mtime    time.Time // modification time of the reloaded file
+	Err      error     // non-nil if the file changed but could not be opened
+}
+
+// filestamp identifies a version of an MBTiles file.
+type filestamp struct {
+	fi      os.FileInfo
+	wal     os.FileInfo // nil if there is no write-ahead log
+	version int64       // SQLite data_version
+}
+
+func statfile(fn string) (filestamp, error) {
+	var fs filestamp
+	var err error
+	if fs.fi, err = os.Stat(fn); err != nil {
+		return fs, err
+	}
+	if wal, err := os.Stat(fn + "-wal"); err == nil {
+		fs.wal = wal
+	}
+	return fs, nil
+}
+
+func samefileinfo(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// dataVersion returns the data_version of the database, that is
+// changed when other connections commit changes.
+func (ms *mapsql) dataVersion() (int64, error) {
+	var v int64
+	err := ms.watch.QueryRowContext(context.Background(), "pragma data_version").Scan(&v)
+	return v, err
+}
+
+// changed reports if the file fn differs from the one ms was opened from.
+// A file replaced by another one, changes to its size or modification time,
+// or changes of its write-ahead log or data_version are detected.
+func (ms *mapsql) changed(fn string) (bool, error) {
+	st, err := statfile(fn)
+	if err != nil {
+		return false, err
+	}
+	if !samefileinfo(st.fi, ms.stamp.fi) || !samefileinfo(st.wal, ms.stamp.wal) {
+		return true, nil
+	}
+	v, err := ms.dataVersion()
+	if err != nil {
+		return false, err
+	}
+	return v != ms.stamp.version, nil
+}
+
+// Reload reopens the file of mbt if it has changed since it was opened.
+// Reads in progress and open iterators finish using the old database,
+// which is closed afterwards. It reports if the file was reloaded.
+func (mbt *Map) Reload() (bool, error) {
+	mbt.reloadmtx.Lock()
+	defer mbt.reloadmtx.Unlock()
+
+	ms, err := mbt.acquire()
+	if err != nil {
+		return false, err
+	}
+	changed, err := ms.changed(mbt.Filename)
+	ms.release()
+	if err != nil || !changed {
+		return false, err
+	}
+
+	nms, err := openmapsql(mbt.Filename)
+	if err != nil {
+		return false, err
+	}
+	mbt.mtx.Lock()
+	old := mbt.ms
+	if old == nil {
+		// closed in the meantime
+		mbt.mtx.Unlock()
+		nms.close()
+		return false, ErrClosed
+	}
+	mbt.setmapsql(nms)
+	mbt.mtx.Unlock()
+
+	go func() {
+		old.wg.Wait()
+		old.close()
+	}()
+	return true, nil
+}
+
+// OnReload sets the function called by auto reload after
+// the file was reloaded, or reloading it failed. Events
+// are logged if fn is nil.
+func (mbt *Map) OnReload(fn func(ReloadEvent)) {
+	mbt.mtx.Lock()
+	defer mbt.mtx.Unlock()
+	mbt.onreload = fn
+}
+
+// SetAutoReload enables or disables checking the file for
+// modifications every ReloadInterval, and reloading it if needed.
 func (mbt *Map) SetAutoReload(autoreload bool) {
 	mbt.mtx.Lock()
 	defer mbt.mtx.Unlock()
-	if (mbt.ar != nil) == autoreload {
+	if (mbt.ar != nil) == autoreload || mbt.ms == nil {
 		return
 	}
 	if mbt.ar != nil {
@@ -21,31 +135,44 @@ func (mbt *Map) SetAutoReload(autoreload bool) {
 	mbt.ar = ch
 
 	go func() {
-		tick := time.Tick(time.Second)
+		t := time.NewTicker(ReloadInterval)
+		defer t.Stop()
+		var lasterr string // report repeated errors only once
 		for {
 			select {
 			case <-ch:
 				return
-			case <-tick:
-				fi, err := os.Stat(mbt.Filename)
-				if err != nil && fi.ModTime() != mbt.Mtime {
-					mbt.mtx.Lock()
-					// check if we were closed in the meantime
-					if mbt.db != nil {
-						var a, tmp mapsql
-						var b *mapsql
-						a, *b = *b, a
-						mtime, err := tmp.open(mbt.Filename)
-						if err == nil {
-							tmp, mbt.mapsql = mbt.mapsql, tmp
-							mbt.Mtime = mtime
-							log.Println("database reloaded:", mtime)
-						}
-						tmp.close()
-					}
-					mbt.mtx.Unlock()
+			case <-t.C:
+			}
+			ok, err := mbt.Reload()
+			if err == ErrClosed {
+				return
+			}
+			if err != nil {
+				if err.Error() == lasterr {
+					continue
 				}
+				lasterr = err.Error()
+			} else {
+				lasterr = ""
+			}
+			if ok || err != nil {
+				mbt.notify(ReloadEvent{Filename: mbt.Filename, Mtime: mbt.ModTime(), Err: err})
 			}
 		}
 	}()
 }
+
+func (mbt *Map) notify(ev ReloadEvent) {
+	mbt.mtx.RLock()
+	fn := mbt.onreload
+	mbt.mtx.RUnlock()
+	switch {
+	case fn != nil:
+		fn(ev)
+	case ev.Err != nil:
+		log.Println("database reload:", ev.Err)
+	default:
+		log.Println("database reloaded:", ev.Mtime)
+	}
+}
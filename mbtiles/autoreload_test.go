@@ -0,0 +1,180 @@
+package mbtiles
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// replaceTestFile replaces fn with a new file of version v by renaming.
+func replaceTestFile(t *testing.T, fn string, maxz, v int) {
+	tmp := fn + ".new"
+	writeTestFile(t, tmp, maxz, v)
+	if err := os.Rename(tmp, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// updateTestFile changes the tiles of fn to version v in place.
+func updateTestFile(t *testing.T, fn string, maxz, v int) {
+	w, err := OpenWriter(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for z := 0; z <= maxz; z++ {
+		for x := 0; x < 1<<uint(z); x++ {
+			for y := 0; y < 1<<uint(z); y++ {
+				if err = w.PutTile(z, x, y, testTile(v, z, x, y)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkTile(t *testing.T, mbt *Map, v, z, x, y int) {
+	t.Helper()
+	data, err := mbt.GetTile(z, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testTile(v, z, x, y); !bytes.Equal(data, want) {
+		t.Fatalf("tile %d/%d/%d: got %q, want %q", z, x, y, data, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, fn string, maxz, v int)
+		iterv  int // version seen by an iterator opened before the reload
+	}{
+		{"rename", replaceTestFile, 1},
+		{"inplace", updateTestFile, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, cleanup := tempFile(t, "reload.mbtiles")
+			defer cleanup()
+			writeTestFile(t, fn, 2, 1)
+			mbt, err := Open(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mbt.Close()
+
+			if ok, err := mbt.Reload(); ok || err != nil {
+				t.Fatalf("Reload of unchanged file: got %v, %v", ok, err)
+			}
+			checkTile(t, mbt, 1, 2, 1, 3)
+
+			tt.modify(t, fn, 2, 2)
+
+			// read in flight across the reload, it can't be opened
+			// before an in place update that needs exclusive access
+			it := mbt.Tiles(nil)
+			defer it.Close()
+			if !it.Next() {
+				t.Fatal("no tiles:", it.Err())
+			}
+
+			if ok, err := mbt.Reload(); !ok || err != nil {
+				t.Fatalf("Reload of modified file: got %v, %v", ok, err)
+			}
+			checkTile(t, mbt, 2, 2, 1, 3)
+
+			n := 1
+			for it.Next() {
+				z, x, y, data := it.Tile()
+				if want := testTile(tt.iterv, z, x, y); !bytes.Equal(data, want) {
+					t.Fatalf("iterator tile %d/%d/%d: got %q, want %q", z, x, y, data, want)
+				}
+				n++
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n != 21 {
+				t.Fatalf("iterator returned %d tiles, want 21", n)
+			}
+		})
+	}
+}
+
+func TestAutoReload(t *testing.T) {
+	defer func(d time.Duration) { ReloadInterval = d }(ReloadInterval)
+	ReloadInterval = 10 * time.Millisecond
+
+	fn, cleanup := tempFile(t, "autoreload.mbtiles")
+	defer cleanup()
+	writeTestFile(t, fn, 1, 1)
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+
+	events := make(chan ReloadEvent, 10)
+	mbt.OnReload(func(ev ReloadEvent) { events <- ev })
+	mbt.SetAutoReload(true)
+
+	replaceTestFile(t, fn, 1, 2)
+	select {
+	case ev := <-events:
+		if ev.Err != nil || ev.Filename != fn {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload event")
+	}
+	checkTile(t, mbt, 2, 1, 1, 0)
+	if name := mbt.Metadata().Name; name != "v2" {
+		t.Fatalf("metadata name: got %q, want v2", name)
+	}
+}
+
+func TestCloseDuringReload(t *testing.T) {
+	fn, cleanup := tempFile(t, "close.mbtiles")
+	defer cleanup()
+	writeTestFile(t, fn, 1, 1)
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		mtime := time.Now()
+		for {
+			// a new modification time makes Reload reopen the file
+			mtime = mtime.Add(time.Second)
+			if err := os.Chtimes(fn, mtime, mtime); err != nil {
+				done <- err
+				return
+			}
+			if _, err := mbt.Reload(); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := mbt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Fatalf("Reload after Close: got %v, want %v", err, ErrClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload did not return after Close")
+	}
+	if err := mbt.Close(); err != ErrClosed {
+		t.Fatalf("second Close: got %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,255 @@
+package mbtiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DirOptions control the layout of tile directory trees
+// used by Export and Import. Tiles are stored as {z}/{x}/{y}.{ext}.
+type DirOptions struct {
+	// TMS selects TMS row numbering for file names, XYZ is used otherwise.
+	TMS bool
+
+	// Grids exports or imports UTFGrids as {z}/{x}/{y}.grid.json files.
+	Grids bool
+
+	// NoMetadata omits writing or reading metadata.json.
+	NoMetadata bool
+
+	// Ext is the extension of exported tile files without the dot.
+	// If empty, it is detected from the data of each tile.
+	Ext string
+
+	// Schema is the schema of the file created by Import.
+	Schema Schema
+}
+
+const (
+	metadataFile = "metadata.json"
+	gridExt      = ".grid.json"
+)
+
+// Export writes the tiles of mbt into the directory tree at dir.
+func Export(mbt *Map, dir string, opt *DirOptions) error {
+	if opt == nil {
+		opt = new(DirOptions)
+	}
+	if !opt.NoMetadata {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(mbt.Metadata().Values(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, metadataFile), data, 0666); err != nil {
+			return err
+		}
+	}
+
+	defext := mbt.Metadata().TileFormat().Ext()
+	if defext == "" {
+		defext = PNG.Ext()
+	}
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	for it.Next() {
+		z, x, y, data := it.Tile()
+		ext := opt.Ext
+		if ext == "" {
+			if f, _ := DetectFormat(data); f != UnknownFormat {
+				ext = f.Ext()
+			} else {
+				ext = defext
+			}
+		}
+		fy := y
+		if !opt.TMS {
+			fy = tile.FlipY(z, y)
+		}
+		base := filepath.Join(dir, strconv.Itoa(z), strconv.Itoa(x), strconv.Itoa(fy))
+		if err := os.MkdirAll(filepath.Dir(base), 0777); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(base+"."+ext, data, 0666); err != nil {
+			return err
+		}
+		if opt.Grids {
+			grid, err := mbt.GetGridData(z, x, y, "")
+			if errors.Is(err, ErrTileNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(base+gridExt, grid, 0666); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
+
+// Import creates the MBTiles file fn from the directory tree at dir.
+// The metadata is read from metadata.json. If it is missing or incomplete,
+// the name, format and zoom range are derived from dir and its content.
+// Files without a tile or grid extension are ignored. Import fails for
+// invalid tile coordinates, and for tiles with an extension of a format
+// other than that of the first tile.
+func Import(dir, fn string, opt *DirOptions) error {
+	if opt == nil {
+		opt = new(DirOptions)
+	}
+	var values map[string]string
+	if !opt.NoMetadata {
+		var err error
+		values, err = readMetadataFile(filepath.Join(dir, metadataFile))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if values == nil {
+		values = make(map[string]string)
+	}
+
+	w, err := CreateSchema(fn, opt.Schema)
+	if err != nil {
+		return err
+	}
+	minz, maxz, format := -1, -1, UnknownFormat
+	err = filepath.Walk(dir, func(pth string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		z, x, y, ext, ok := parseTilePath(dir, pth)
+		if !ok {
+			return nil
+		}
+		if err := CheckTile(z, x, y); err != nil {
+			return fmt.Errorf("%s: %w", pth, err)
+		}
+		if !opt.TMS {
+			y = tile.FlipY(z, y)
+		}
+		if ext != gridExt {
+			f := ParseFormat(ext)
+			if format == UnknownFormat {
+				format = f
+			} else if f != format {
+				return fmt.Errorf("%s: %v tile among %v tiles", pth, f, format)
+			}
+		}
+		data, err := ioutil.ReadFile(pth)
+		if err != nil {
+			return err
+		}
+		if ext == gridExt {
+			if !opt.Grids {
+				return nil
+			}
+			return importGrid(w, z, x, y, data)
+		}
+		if minz < 0 || z < minz {
+			minz = z
+		}
+		if z > maxz {
+			maxz = z
+		}
+		return w.PutTile(z, x, y, data)
+	})
+	if err == nil {
+		if values["name"] == "" {
+			values["name"] = filepath.Base(filepath.Clean(dir))
+		}
+		if values["format"] == "" && format != UnknownFormat {
+			values["format"] = format.String()
+		}
+		if minz >= 0 && values["minzoom"] == "" && values["maxzoom"] == "" {
+			values["minzoom"] = strconv.Itoa(minz)
+			values["maxzoom"] = strconv.Itoa(maxz)
+		}
+		err = w.WriteMetadata(ParseMetadata(values))
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fn)
+	}
+	return err
+}
+
+// readMetadataFile reads metadata from a JSON object.
+// Values that are not strings are kept in JSON form.
+func readMetadataFile(fn string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	values := make(map[string]string)
+	for k, v := range m {
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			values[k] = s
+		} else {
+			values[k] = string(v)
+		}
+	}
+	return values, nil
+}
+
+// parseTilePath parses pth of the form dir/{z}/{x}/{y}{ext}
+// with a tile format or grid extension.
+func parseTilePath(dir, pth string) (z, x, y int, ext string, ok bool) {
+	rel, err := filepath.Rel(dir, pth)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 {
+		return
+	}
+	name := parts[2]
+	if strings.HasSuffix(name, gridExt) {
+		ext = gridExt
+	} else {
+		ext = filepath.Ext(name)
+	}
+	if ext != gridExt && ParseFormat(ext) == UnknownFormat {
+		return
+	}
+	parts[2] = strings.TrimSuffix(name, ext)
+	v := make([]int, 3)
+	for i, s := range parts {
+		if v[i], err = strconv.Atoi(s); err != nil {
+			return
+		}
+	}
+	return v[0], v[1], v[2], ext, true
+}
+
+func importGrid(w *Writer, z, x, y int, data []byte) error {
+	// strip JSONP callback, if any
+	data = bytes.TrimSpace(data)
+	if n := bytes.IndexByte(data, '('); n > 0 && data[0] != '{' {
+		data = bytes.TrimSuffix(bytes.TrimSuffix(data[n+1:], []byte(";")), []byte(")"))
+	}
+	g := new(Grid)
+	if err := json.Unmarshal(data, g); err != nil {
+		return fmt.Errorf("grid %d/%d/%d: %v", z, x, y, err)
+	}
+	return w.WriteGrid(z, x, y, g)
+}
@@ -0,0 +1,90 @@
+package mbtiles
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates the files in dir with their names as content.
+func writeTree(t *testing.T, dir string, files ...string) {
+	for _, name := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(name), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mbtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "tiles")
+	writeTree(t, src, "0/0/0.png", "1/1/0.PNG", "1/1/0.bak", "README.txt", "1/x/0.png")
+	fn := filepath.Join(dir, "import.mbtiles")
+	if err = Import(src, fn, nil); err != nil {
+		t.Fatal(err)
+	}
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	if m := mbt.Metadata(); m.Format != "png" || m.MinZoom != 0 || m.MaxZoom != 1 {
+		t.Errorf("metadata: got format %q, zoom %d-%d", m.Format, m.MinZoom, m.MaxZoom)
+	}
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	var got []string
+	for it.Next() {
+		_, _, _, data := it.Tile()
+		got = append(got, string(data))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "0/0/0.png" || got[1] != "1/1/0.PNG" {
+		t.Errorf("imported tiles: got %q", got)
+	}
+}
+
+func TestImportError(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		err   error
+	}{
+		{"column", []string{"2/4/0.png"}, ErrOutOfRange},
+		{"row", []string{"2/0/4.png"}, ErrOutOfRange},
+		{"negative", []string{"2/-1/0.png"}, ErrOutOfRange},
+		{"zoom", []string{"32/0/0.png"}, ErrOutOfRange},
+		{"mixed", []string{"1/0/0.png", "1/0/1.jpg"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "mbtiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			src := filepath.Join(dir, "tiles")
+			writeTree(t, src, tt.files...)
+			fn := filepath.Join(dir, "import.mbtiles")
+			err = Import(src, fn, nil)
+			if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if _, err := os.Stat(fn); !os.IsNotExist(err) {
+				t.Errorf("failed import left %s behind", fn)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mbtiles
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"github.com/tajtiattila/go-mbtiles/tile"
+)
+
+var (
+	// ErrClosed is returned when a closed tileset is used.
+	ErrClosed = errors.New("map is closed")
+
+	// ErrOutOfRange means that tile coordinates are invalid
+	// for the zoom level.
+	ErrOutOfRange = errors.New("tile coordinates out of range")
+
+	// ErrZoomRange means that a tile was requested outside
+	// the zoom range declared in the metadata.
+	ErrZoomRange = errors.New("zoom level outside of tileset zoom range")
+
+	// ErrCorrupt means that the database or tile data is damaged.
+	ErrCorrupt = errors.New("corrupt data")
+
+	// ErrKeyConflict means that different data was written for
+	// an UTFGrid key in a deduplicated file, where key data is shared.
+	ErrKeyConflict = errors.New("conflicting data for grid key")
+)
+
+// TileError records an error concerning a single tile.
+// Err is one of ErrOutOfRange, ErrZoomRange or ErrCorrupt.
+// TileErrors for missing tiles match ErrTileNotFound with errors.Is,
+// but missing tiles within range are reported by ErrTileNotFound itself.
+type TileError struct {
+	Z, X, Y int   // TMS coordinates
+	Err     error // one of the errors above
+	Cause   error // underlying error, if any
+}
+
+func (e *TileError) Error() string {
+	s := fmt.Sprintf("tile %d/%d/%d: %v", e.Z, e.X, e.Y, e.Err)
+	if e.Cause != nil {
+		s += ": " + e.Cause.Error()
+	}
+	return s
+}
+
+func (e *TileError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports if the tile is missing for target ErrTileNotFound.
+func (e *TileError) Is(target error) bool {
+	return target == ErrTileNotFound && (e.Err == ErrOutOfRange || e.Err == ErrZoomRange)
+}
+
+// CheckTile returns a *TileError with ErrOutOfRange if
+// z, x, y are not valid tile coordinates.
+func CheckTile(z, x, y int) error {
+	if !(tile.Tile{Z: z, X: x, Y: y}).Valid() {
+		return &TileError{Z: z, X: x, Y: y, Err: ErrOutOfRange}
+	}
+	return nil
+}
+
+// notfound returns the error for tile z, x, y missing from ms.
+func (ms *mapsql) notfound(z, x, y int) error {
+	md := ms.metadata
+	if md.has(keyMinZoom) && md.has(keyMaxZoom) &&
+		!md.malformed(keyMinZoom) && !md.malformed(keyMaxZoom) &&
+		(z < md.MinZoom || z > md.MaxZoom) {
+		return &TileError{Z: z, X: x, Y: y, Err: ErrZoomRange}
+	}
+	return ErrTileNotFound
+}
+
+// tileerr wraps database errors reporting damaged files into a *TileError.
+func tileerr(err error, z, x, y int) error {
+	if e, ok := err.(sqlite3.Error); ok && (e.Code == sqlite3.ErrCorrupt || e.Code == sqlite3.ErrNotADB) {
+		return corrupt(err, z, x, y)
+	}
+	return err
+}
+
+func corrupt(cause error, z, x, y int) error {
+	return &TileError{Z: z, X: x, Y: y, Err: ErrCorrupt, Cause: cause}
+}
@@ -0,0 +1,122 @@
+package mbtiles
+
+import (
+	"bytes"
+	"strings"
+)
+
+// TileFormat is the encoding of tile data.
+type TileFormat int
+
+const (
+	UnknownFormat TileFormat = iota
+	PNG
+	JPEG
+	WebP
+	GIF
+	PBF // Mapbox Vector Tile
+)
+
+var formatInfo = []struct {
+	name, ctype string
+}{
+	UnknownFormat: {"", "application/octet-stream"},
+	PNG:           {"png", "image/png"},
+	JPEG:          {"jpg", "image/jpeg"},
+	WebP:          {"webp", "image/webp"},
+	GIF:           {"gif", "image/gif"},
+	PBF:           {"pbf", "application/x-protobuf"},
+}
+
+// String returns the name of f as used in the format metadata.
+func (f TileFormat) String() string {
+	if f < 0 || int(f) >= len(formatInfo) {
+		f = UnknownFormat
+	}
+	return formatInfo[f].name
+}
+
+// Ext returns the file name extension for tiles of format f, without the dot.
+func (f TileFormat) Ext() string {
+	return f.String()
+}
+
+// ContentType returns the MIME type of tiles with format f.
+func (f TileFormat) ContentType() string {
+	if f < 0 || int(f) >= len(formatInfo) {
+		f = UnknownFormat
+	}
+	return formatInfo[f].ctype
+}
+
+// ParseFormat returns the format for a format metadata value
+// or file extension. Extensions are accepted with or without the dot.
+func ParseFormat(s string) TileFormat {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "png":
+		return PNG
+	case "jpg", "jpeg":
+		return JPEG
+	case "webp":
+		return WebP
+	case "gif":
+		return GIF
+	case "pbf", "mvt":
+		return PBF
+	}
+	return UnknownFormat
+}
+
+// Compression is the compression applied to tile data
+// on top of the tile format. Only vector tiles are compressed.
+type Compression int
+
+const (
+	Uncompressed Compression = iota
+	Gzip
+	Zlib
+)
+
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Zlib:
+		return "zlib"
+	}
+	return "none"
+}
+
+var (
+	pngMagic  = []byte("\x89PNG\r\n\x1a\n")
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+)
+
+// DetectFormat identifies the format of tile data from its first bytes.
+// Gzip and zlib compressed data is assumed to be a vector tile.
+func DetectFormat(data []byte) (TileFormat, Compression) {
+	switch {
+	case bytes.HasPrefix(data, pngMagic):
+		return PNG, Uncompressed
+	case bytes.HasPrefix(data, jpegMagic):
+		return JPEG, Uncompressed
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return WebP, Uncompressed
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return GIF, Uncompressed
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		return PBF, Gzip
+	case len(data) >= 2 && data[0]&0x0f == 8 && (uint(data[0])<<8|uint(data[1]))%31 == 0:
+		// zlib header with deflate compression method
+		return PBF, Zlib
+	case len(data) >= 1 && data[0] == 0x1a:
+		// field 3 (layers) of a vector tile, length-delimited
+		return PBF, Uncompressed
+	}
+	return UnknownFormat, Uncompressed
+}
+
+// TileFormat returns the format declared in md.
+func (md *Metadata) TileFormat() TileFormat {
+	return ParseFormat(md.Format)
+}
@@ -0,0 +1,212 @@
+package mbtiles
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// Grid is an UTFGrid of a tile.
+//
+// Each string of Grid is a row of the grid, each rune of a row
+// encodes an index into Keys. The empty key means no feature.
+// Data holds the JSON objects of the keys, if any.
+type Grid struct {
+	Grid []string                   `json:"grid"`
+	Keys []string                   `json:"keys"`
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// decodeGridRune returns the key index encoded by r.
+func decodeGridRune(r rune) int {
+	if r >= 93 {
+		r--
+	}
+	if r >= 35 {
+		r--
+	}
+	return int(r - 32)
+}
+
+// KeyAt returns the key of the feature at pixel px, py of a tile
+// with tilesize pixels, counted from the top left corner.
+// It returns the empty string if there is no feature at the pixel.
+func (g *Grid) KeyAt(px, py, tilesize int) string {
+	n := len(g.Grid)
+	if n == 0 || tilesize <= 0 || px < 0 || py < 0 || px >= tilesize || py >= tilesize {
+		return ""
+	}
+	row := g.Grid[py*n/tilesize]
+	col := px * utf8.RuneCountInString(row) / tilesize
+	for _, r := range row {
+		if col == 0 {
+			if i := decodeGridRune(r); i >= 0 && i < len(g.Keys) {
+				return g.Keys[i]
+			}
+			return ""
+		}
+		col--
+	}
+	return ""
+}
+
+// DataAt returns the key and data of the feature at
+// pixel px, py like KeyAt. Data is nil if the key has no data.
+func (g *Grid) DataAt(px, py, tilesize int) (key string, data json.RawMessage) {
+	key = g.KeyAt(px, py, tilesize)
+	if key != "" {
+		data = g.Data[key]
+	}
+	return key, data
+}
+
+// ErrCallback is returned for JSONP callback names
+// that are not JavaScript identifiers.
+var ErrCallback = errors.New("invalid JSONP callback name")
+
+// validCallback reports if callback is a (dotted) JavaScript identifier.
+func validCallback(callback string) bool {
+	if callback == "" {
+		return false
+	}
+	start := true
+	for _, r := range callback {
+		switch {
+		case r == '.' && !start:
+			start = true
+			continue
+		case r == '_' || r == '$' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && !start:
+		default:
+			return false
+		}
+		start = false
+	}
+	return !start
+}
+
+// CheckCallback returns ErrCallback if callback is
+// not empty and not a valid JSONP callback name.
+func CheckCallback(callback string) error {
+	if callback != "" && !validCallback(callback) {
+		return ErrCallback
+	}
+	return nil
+}
+
+// WriteJSON writes g to w in JSON format, or in
+// JSONP format if callback is not empty.
+func (g *Grid) WriteJSON(w io.Writer, callback string) error {
+	if err := CheckCallback(callback); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if callback != "" {
+		buf.WriteString(callback + "(")
+	}
+	v := *g
+	if v.Data == nil {
+		v.Data = map[string]json.RawMessage{}
+	}
+	if err := json.NewEncoder(&buf).Encode(&v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // newline from Encode
+	if callback != "" {
+		buf.WriteString(");")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Encode returns g in JSON or JSONP format like WriteJSON.
+func (g *Grid) Encode(callback string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf, callback); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetGrid returns the UTFGrid of tile z, x, y in TMS coordinates.
+func (mbt *Map) GetGrid(z, x, y int) (*Grid, error) {
+	return mbt.grid(context.Background(), z, x, y)
+}
+
+func (mbt *Map) grid(ctx context.Context, z, x, y int) (*Grid, error) {
+	if err := CheckTile(z, x, y); err != nil {
+		return nil, err
+	}
+	ms, err := mbt.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer ms.release()
+	if ms.gridStmt == nil {
+		return nil, ms.notfound(z, x, y)
+	}
+	rows, err := ms.gridStmt.QueryContext(ctx, z, x, y)
+	if err != nil {
+		return nil, tileerr(err, z, x, y)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, tileerr(err, z, x, y)
+		}
+		return nil, ms.notfound(z, x, y)
+	}
+	var blob []byte
+	if err = rows.Scan(&blob); err != nil {
+		return nil, tileerr(err, z, x, y)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return nil, corrupt(err, z, x, y)
+	}
+	g := new(Grid)
+	if err = json.NewDecoder(zr).Decode(g); err != nil {
+		return nil, corrupt(err, z, x, y)
+	}
+	g.Data = nil // data is stored separately
+
+	rows, err = ms.gridDataStmt.QueryContext(ctx, z, x, y)
+	if err != nil {
+		return nil, tileerr(err, z, x, y)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err = rows.Scan(&key, &data); err != nil {
+			return nil, tileerr(err, z, x, y)
+		}
+		if !json.Valid(data) {
+			return nil, corrupt(errors.New("invalid JSON in data of key "+key), z, x, y)
+		}
+		if g.Data == nil {
+			g.Data = make(map[string]json.RawMessage)
+		}
+		g.Data[key] = json.RawMessage(data)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, tileerr(err, z, x, y)
+	}
+	return g, nil
+}
+
+// WriteGrid stores g for the tile at z, x, y.
+func (w *Writer) WriteGrid(z, x, y int, g *Grid) error {
+	grid, err := json.Marshal(struct {
+		Grid []string `json:"grid"`
+		Keys []string `json:"keys"`
+	}{g.Grid, g.Keys})
+	if err != nil {
+		return err
+	}
+	return w.PutGrid(z, x, y, grid, g.Data)
+}
@@ -0,0 +1,170 @@
+package mbtiles
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// encodeGridRune returns the rune encoding key index i in UTFGrids.
+func encodeGridRune(i int) rune {
+	r := rune(i + 32)
+	if r >= 34 {
+		r++
+	}
+	if r >= 92 {
+		r++
+	}
+	return r
+}
+
+func TestDecodeGridRune(t *testing.T) {
+	tests := []struct {
+		r rune
+		i int
+	}{
+		{' ', 0},
+		{'!', 1},
+		{'#', 2}, // 34 is skipped
+		{'[', 58},
+		{']', 59}, // 92 is skipped
+		{'~', 92},
+		{127, 93},
+		{'é', 199},
+	}
+	for _, tt := range tests {
+		if got := decodeGridRune(tt.r); got != tt.i {
+			t.Errorf("decodeGridRune(%q) = %d, want %d", tt.r, got, tt.i)
+		}
+		if got := encodeGridRune(tt.i); got != tt.r {
+			t.Errorf("encodeGridRune(%d) = %q, want %q", tt.i, got, tt.r)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if got := decodeGridRune(encodeGridRune(i)); got != i {
+			t.Fatalf("index %d decoded as %d", i, got)
+		}
+	}
+}
+
+func TestKeyAt(t *testing.T) {
+	keys := make([]string, 200)
+	for i := 1; i < len(keys); i++ {
+		keys[i] = string('A'+rune(i%26)) + strings.Repeat("x", i/26)
+	}
+	row := func(i ...int) string {
+		r := make([]rune, len(i))
+		for j, v := range i {
+			r[j] = encodeGridRune(v)
+		}
+		return string(r)
+	}
+	g := &Grid{
+		Grid: []string{
+			row(0, 1, 2, 3),
+			row(58, 59, 60, 93),
+			row(150, 199, 0, 0),
+			row(0, 0, 0, 250), // index out of range
+		},
+		Keys: keys,
+	}
+	tests := []struct {
+		px, py, tilesize int
+		key              string
+	}{
+		{0, 0, 256, ""},
+		{64, 0, 256, keys[1]},
+		{191, 63, 256, keys[2]},
+		{192, 0, 256, keys[3]},
+		{0, 64, 256, keys[58]},
+		{64, 64, 256, keys[59]},
+		{255, 127, 256, keys[93]},
+		{0, 128, 256, keys[150]}, // multi-byte runes
+		{64, 191, 256, keys[199]},
+		{255, 255, 256, ""},
+		{1, 0, 4, keys[1]},
+		{3, 1, 4, keys[93]},
+		{-1, 0, 256, ""},
+		{0, 256, 256, ""},
+		{0, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := g.KeyAt(tt.px, tt.py, tt.tilesize); got != tt.key {
+			t.Errorf("KeyAt(%d, %d, %d) = %q, want %q", tt.px, tt.py, tt.tilesize, got, tt.key)
+		}
+	}
+	if got := new(Grid).KeyAt(0, 0, 256); got != "" {
+		t.Errorf("KeyAt of empty grid = %q", got)
+	}
+}
+
+func TestDataAt(t *testing.T) {
+	g := &Grid{
+		Grid: []string{" !", "!#"},
+		Keys: []string{"", "a", "b"},
+		Data: map[string]json.RawMessage{"a": json.RawMessage(`{"name":"a"}`)},
+	}
+	if key, data := g.DataAt(200, 0, 256); key != "a" || string(data) != `{"name":"a"}` {
+		t.Errorf("DataAt(200, 0): got %q, %s", key, data)
+	}
+	if key, data := g.DataAt(200, 200, 256); key != "b" || data != nil {
+		t.Errorf("DataAt(200, 200): got %q, %s", key, data)
+	}
+	if key, data := g.DataAt(0, 0, 256); key != "" || data != nil {
+		t.Errorf("DataAt(0, 0): got %q, %s", key, data)
+	}
+}
+
+func TestValidCallback(t *testing.T) {
+	tests := []struct {
+		callback string
+		valid    bool
+	}{
+		{"cb", true},
+		{"_cb$1", true},
+		{"$", true},
+		{"jQuery123.handlers.grid_4", true},
+		{"", false},
+		{"1cb", false},
+		{"cb.1", false},
+		{"cb.", false},
+		{".cb", false},
+		{"cb..x", false},
+		{"cb-x", false},
+		{"cb(1)", false},
+		{"alert(document.cookie)//", false},
+		{"</script><script>", false},
+		{"cb x", false},
+		{"é", false},
+	}
+	for _, tt := range tests {
+		if got := validCallback(tt.callback); got != tt.valid {
+			t.Errorf("validCallback(%q) = %v, want %v", tt.callback, got, tt.valid)
+		}
+		if err := CheckCallback(tt.callback); tt.callback != "" && (err == nil) != tt.valid {
+			t.Errorf("CheckCallback(%q) = %v", tt.callback, err)
+		}
+	}
+	if err := CheckCallback(""); err != nil {
+		t.Errorf("CheckCallback of empty callback = %v", err)
+	}
+}
+
+func TestGridEncode(t *testing.T) {
+	g := &Grid{Grid: []string{" "}, Keys: []string{""}}
+	tests := []struct {
+		callback string
+		want     string
+		err      error
+	}{
+		{"", `{"grid":[" "],"keys":[""],"data":{}}`, nil},
+		{"grid", `grid({"grid":[" "],"keys":[""],"data":{}});`, nil},
+		{"alert(1);x", "", ErrCallback},
+	}
+	for _, tt := range tests {
+		got, err := g.Encode(tt.callback)
+		if string(got) != tt.want || err != tt.err {
+			t.Errorf("Encode(%q) = %s, %v, want %s, %v", tt.callback, got, err, tt.want, tt.err)
+		}
+	}
+}
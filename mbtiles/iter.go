@@ -0,0 +1,168 @@
+package mbtiles
+
+import (
+	"database/sql"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"math"
+)
+
+// TileFilter restricts the tiles visited by Map.Tiles.
+// The zero value matches all tiles.
+type TileFilter struct {
+	// MinZoom is the lowest zoom level visited.
+	MinZoom int
+
+	// MaxZoom is the highest zoom level visited if HasMaxZoom
+	// is set, otherwise there is no upper limit.
+	MaxZoom    int
+	HasMaxZoom bool
+
+	// Bounds, if not nil, limits tiles to those
+	// intersecting the lon/lat bounding box.
+	Bounds *MbtBounds
+}
+
+// TileIter iterates over tiles of a Map. Its usage is similar to sql.Rows:
+//
+//	it := mbt.Tiles(nil)
+//	defer it.Close()
+//	for it.Next() {
+//		z, x, y, data := it.Tile()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Tiles are visited in ascending zoom, column and row order.
+type TileIter struct {
+	f    TileFilter
+	ms   *mapsql // released on Close
+	stmt *sql.Stmt
+	rows *sql.Rows
+	z    int // next zoom level to query
+	maxz int
+	err  error
+
+	tz, tx, ty int
+	data       []byte
+}
+
+// Tiles returns an iterator over the tiles matching f.
+// A nil filter matches all tiles. Tile data is streamed from
+// the database, only the current tile is held in memory.
+// The iterator uses the database as it was when Tiles was called,
+// even if mbt is reloaded in the meantime. It must be closed after use,
+// Close of mbt waits for open iterators.
+func (mbt *Map) Tiles(f *TileFilter) *TileIter {
+	it := new(TileIter)
+	if f != nil {
+		it.f = *f
+	}
+
+	it.ms, it.err = mbt.acquire()
+	if it.err != nil {
+		return it
+	}
+
+	var ok bool
+	it.z, it.maxz, ok, it.err = it.ms.zoomRange()
+	if it.err != nil {
+		return it
+	}
+	if !ok {
+		// no tiles at all
+		it.z, it.maxz = 0, -1
+		return it
+	}
+	if it.f.MinZoom > it.z {
+		it.z = it.f.MinZoom
+	}
+	if it.f.HasMaxZoom && it.f.MaxZoom < it.maxz {
+		it.maxz = it.f.MaxZoom
+	}
+	it.stmt, it.err = it.ms.db.Prepare(it.ms.queries.tiles + `
+where zoom_level = ?1 and tile_column between ?2 and ?3 and tile_row between ?4 and ?5
+order by zoom_level, tile_column, tile_row`)
+	return it
+}
+
+// Next advances the iterator to the next tile.
+// It returns false when there are no more tiles or
+// an error occurred.
+func (it *TileIter) Next() bool {
+	for it.err == nil {
+		if it.rows != nil {
+			if it.rows.Next() {
+				it.err = it.rows.Scan(&it.tz, &it.tx, &it.ty, &it.data)
+				return it.err == nil
+			}
+			it.err = it.rows.Err()
+			it.rows.Close()
+			it.rows = nil
+			continue
+		}
+		if it.z > it.maxz {
+			return false
+		}
+		x0, y0, x1, y1 := math.MinInt32, math.MinInt32, math.MaxInt32, math.MaxInt32
+		if it.f.Bounds != nil {
+			x0, y0, x1, y1 = tileRange(*it.f.Bounds, it.z)
+		}
+		it.rows, it.err = it.stmt.Query(it.z, x0, x1, y0, y1)
+		it.z++
+	}
+	return false
+}
+
+// Tile returns the coordinates and data of the current tile.
+// The data is valid only until the next call to Next.
+func (it *TileIter) Tile() (z, x, y int, data []byte) {
+	return it.tz, it.tx, it.ty, it.data
+}
+
+// Err returns the error encountered during iteration, if any.
+func (it *TileIter) Err() error {
+	return it.err
+}
+
+// Close releases the resources of the iterator.
+func (it *TileIter) Close() error {
+	if it.rows != nil {
+		it.rows.Close()
+		it.rows = nil
+	}
+	if it.stmt != nil {
+		it.stmt.Close()
+		it.stmt = nil
+	}
+	if it.ms != nil {
+		it.ms.release()
+		it.ms = nil
+	}
+	it.maxz = -1
+	return nil
+}
+
+// zoomRange returns the lowest and highest zoom level of the tiles in ms.
+// Ok is false if there are no tiles.
+func (ms *mapsql) zoomRange() (minz, maxz int, ok bool, err error) {
+	var n0, n1 sql.NullInt64
+	if err = ms.db.QueryRow(ms.queries.zooms).Scan(&n0, &n1); err != nil {
+		return 0, 0, false, err
+	}
+	return int(n0.Int64), int(n1.Int64), n0.Valid, nil
+}
+
+// tileRange returns the TMS tile range covering b at zoom level z.
+func tileRange(b MbtBounds, z int) (x0, y0, x1, y1 int) {
+	x0, y1, x1, y0 = tile.Range(tile.Bounds{W: b.W, S: b.S, E: b.E, N: b.N}, z)
+	return x0, tile.FlipY(z, y0), x1, tile.FlipY(z, y1)
+}
+
+// tileBounds returns the lon/lat bounds of the TMS tile range
+// x0, y0 - x1, y1 at zoom level z.
+func tileBounds(z, x0, y0, x1, y1 int) MbtBounds {
+	b := tile.RangeBounds(z, x0, tile.FlipY(z, y1), x1, tile.FlipY(z, y0))
+	return MbtBounds{W: b.W, S: b.S, E: b.E, N: b.N}
+}
@@ -0,0 +1,38 @@
+package mbtiles
+
+import "testing"
+
+func TestTileFilter(t *testing.T) {
+	mbt, cleanup := openTestFile(t, 3)
+	defer cleanup()
+	defer mbt.Close()
+	tests := []struct {
+		name string
+		f    *TileFilter
+		n    int
+	}{
+		{"nil", nil, 85},
+		{"zero", &TileFilter{}, 85},
+		{"minzoom", &TileFilter{MinZoom: 2}, 80},
+		{"maxzoom", &TileFilter{MaxZoom: 1, HasMaxZoom: true}, 5},
+		{"zoom 0", &TileFilter{HasMaxZoom: true}, 1},
+		{"zoom 2", &TileFilter{MinZoom: 2, MaxZoom: 2, HasMaxZoom: true}, 16},
+		{"empty zoom range", &TileFilter{MinZoom: 2, MaxZoom: 1, HasMaxZoom: true}, 0},
+		{"bounds", &TileFilter{Bounds: &MbtBounds{W: 10, S: 10, E: 20, N: 20}}, 4},
+		{"bounds and zoom", &TileFilter{MinZoom: 1, Bounds: &MbtBounds{W: -10, S: -10, E: 10, N: 10}}, 4 + 4 + 4},
+	}
+	for _, tt := range tests {
+		it := mbt.Tiles(tt.f)
+		n := 0
+		for it.Next() {
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		it.Close()
+		if n != tt.n {
+			t.Errorf("%s: got %d tiles, want %d", tt.name, n, tt.n)
+		}
+	}
+}
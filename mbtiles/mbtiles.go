@@ -1,190 +1,274 @@
 package mbtiles
 
 import (
-	"bytes"
-	"compress/zlib"
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	_ "github.com/mattn/go-sqlite3"
-	"os"
+	"net/url"
+	"runtime"
 	"sync"
 	"time"
 )
 
+// ErrTileNotFound is returned for tiles missing from a tileset.
 var ErrTileNotFound = errors.New("tile does not exist")
 
 type mapsql struct {
 	db                               *sql.DB
 	tileStmt, gridStmt, gridDataStmt *sql.Stmt
 	metadata                         *Metadata
+	schema                           Schema
+	queries                          *sqlQueries
+
+	// watch is a dedicated connection to query data_version,
+	// that is meaningful only within a single connection
+	watch *sql.Conn
+	stamp filestamp
+
+	// wg counts the reads in progress
+	wg sync.WaitGroup
 }
 
-func (ms *mapsql) open(fn string) (time.Time, error) {
-	fi, err := os.Stat(fn)
-	var tnil time.Time
-	if err != nil {
-		return tnil, err
+func openmapsql(fn string) (*mapsql, error) {
+	ms := new(mapsql)
+	var err error
+	// stat before opening, so changes made during open
+	// are detected on the next check
+	if ms.stamp, err = statfile(fn); err != nil {
+		return nil, err
 	}
-	mtime := fi.ModTime()
 
 	ok := false
 	defer func() {
 		if !ok {
-			if ms.tileStmt != nil {
-				ms.tileStmt.Close()
-			}
-			if ms.gridStmt != nil {
-				ms.gridStmt.Close()
-			}
-			if ms.gridDataStmt != nil {
-				ms.gridDataStmt.Close()
-			}
-			if ms.db != nil {
-				ms.db.Close()
-			}
+			ms.close()
 		}
 	}()
 
-	ms.db, err = sql.Open("sqlite3", fn)
+	ms.db, err = sql.Open("sqlite3", readonlyDSN(fn))
 	if err != nil {
-		return tnil, err
+		return nil, err
 	}
+	// statements are prepared on each connection of the pool as needed,
+	// one more connection is used by watch
+	ms.db.SetMaxOpenConns(maxReaders() + 1)
+	ms.db.SetMaxIdleConns(maxReaders() + 1)
 	ms.metadata, err = mbtMetadata(ms.db)
 	if err != nil {
-		return tnil, err
+		return nil, err
 	}
-	ms.tileStmt, err = ms.db.Prepare(`select tile_data from tiles
-where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
+	q, err := detectSchema(ms.db)
 	if err != nil {
-		return tnil, err
+		return nil, err
 	}
-	ms.gridStmt, err = ms.db.Prepare(`select grid from grids
-where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
+	ms.schema = q.schema
+	ms.queries = q
+	ms.tileStmt, err = ms.db.Prepare(q.tile)
 	if err != nil {
-		return tnil, err
+		return nil, err
 	}
-	ms.gridDataStmt, err = ms.db.Prepare(`select key_name,key_json from grid_data
-where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
-	if err != nil {
-		return tnil, err
+	if q.grid != "" {
+		ms.gridStmt, err = ms.db.Prepare(q.grid)
+		if err != nil {
+			return nil, err
+		}
+		ms.gridDataStmt, err = ms.db.Prepare(q.data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ms.watch, err = ms.db.Conn(context.Background()); err != nil {
+		return nil, err
+	}
+	if ms.stamp.version, err = ms.dataVersion(); err != nil {
+		return nil, err
 	}
 	ok = true
-	return mtime, err
+	return ms, nil
+}
+
+// readonlyDSN returns the data source name to open fn read-only.
+func readonlyDSN(fn string) string {
+	u := url.URL{Scheme: "file", Opaque: (&url.URL{Path: fn}).EscapedPath()}
+	return u.String() + "?mode=ro"
+}
+
+// maxReaders is the size of the connection pool used for reads.
+func maxReaders() int {
+	n := 2 * runtime.GOMAXPROCS(0)
+	if n < 4 {
+		n = 4
+	}
+	return n
+}
+
+// release marks the end of a read started with Map.acquire.
+func (ms *mapsql) release() {
+	ms.wg.Done()
 }
 
 func (ms *mapsql) close() error {
-	ms.tileStmt.Close()
-	ms.gridStmt.Close()
-	ms.gridDataStmt.Close()
-	err := ms.db.Close()
-	ms.db = nil
-	ms.tileStmt = nil
-	ms.gridStmt = nil
-	ms.gridDataStmt = nil
-	return err
+	for _, st := range []*sql.Stmt{ms.tileStmt, ms.gridStmt, ms.gridDataStmt} {
+		if st != nil {
+			st.Close()
+		}
+	}
+	if ms.watch != nil {
+		ms.watch.Close()
+	}
+	if ms.db == nil {
+		return nil
+	}
+	return ms.db.Close()
 }
 
+// Map is an MBTiles file opened for reading.
+// Its methods are safe for concurrent use.
 type Map struct {
-	mapsql
 	Filename string
-	Mtime    time.Time
-	mtx      sync.Mutex
-	ar       chan<- bool
+
+	// Mtime is the modification time of the file when it was opened.
+	//
+	// Deprecated: Mtime is not updated by reloads, use ModTime.
+	Mtime time.Time
+
+	// mtx is held for reading while ms is acquired, and for
+	// writing when ms is replaced or the Map is closed
+	mtx      sync.RWMutex
+	ms       *mapsql
+	metadata *Metadata
+	mtime    time.Time
+
+	reloadmtx sync.Mutex // serializes reloads
+	ar        chan<- bool
+	onreload  func(ReloadEvent)
 }
 
 func Open(dbname string) (*Map, error) {
-	mbt := &Map{Filename: dbname}
-	var err error
-	mbt.Mtime, err = mbt.open(mbt.Filename)
+	ms, err := openmapsql(dbname)
 	if err != nil {
 		return nil, err
 	}
-	return mbt, err
+	mbt := &Map{Filename: dbname}
+	mbt.setmapsql(ms)
+	mbt.Mtime = mbt.mtime
+	return mbt, nil
+}
+
+// setmapsql makes ms current, mbt.mtx must be held for writing
+// unless mbt is not yet shared.
+func (mbt *Map) setmapsql(ms *mapsql) {
+	mbt.ms = ms
+	mbt.metadata = ms.metadata
+	mbt.mtime = ms.stamp.fi.ModTime()
 }
 
+// acquire returns the current database of mbt. The caller must
+// call release on the result when finished with it.
+func (mbt *Map) acquire() (*mapsql, error) {
+	mbt.mtx.RLock()
+	defer mbt.mtx.RUnlock()
+	if mbt.ms == nil {
+		return nil, ErrClosed
+	}
+	mbt.ms.wg.Add(1)
+	return mbt.ms, nil
+}
+
+// Close closes mbt after reads in progress and open iterators are finished.
 func (mbt *Map) Close() error {
 	mbt.mtx.Lock()
-	defer mbt.mtx.Unlock()
+	ms := mbt.ms
+	mbt.ms = nil
 	if mbt.ar != nil {
 		close(mbt.ar)
 		mbt.ar = nil
 	}
-	return mbt.mapsql.close()
+	mbt.mtx.Unlock()
+	if ms == nil {
+		return ErrClosed
+	}
+	ms.wg.Wait()
+	return ms.close()
 }
 
 func (mbt *Map) GetTile(z, x, y int) ([]byte, error) {
-	mbt.mtx.Lock()
-	defer mbt.mtx.Unlock()
-	rows, err := mbt.tileStmt.Query(z, x, y)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	if !rows.Next() {
-		return nil, ErrTileNotFound
-	}
-	var blob []byte
-	if err = rows.Scan(&blob); err == nil {
-		return blob, nil
-	}
-	return nil, err
+	return mbt.GetTileContext(context.Background(), z, x, y)
 }
 
-func (mbt *Map) GetGridData(z, x, y int, callback string) ([]byte, error) {
-	mbt.mtx.Lock()
-	defer mbt.mtx.Unlock()
-	rows, err := mbt.gridStmt.Query(z, x, y)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	if !rows.Next() {
-		return nil, ErrTileNotFound
-	}
-	var blob []byte
-	if err = rows.Scan(&blob); err != nil {
+// GetTileContext returns the data of tile z, x, y in TMS coordinates.
+// The query is interrupted when ctx is done.
+func (mbt *Map) GetTileContext(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := CheckTile(z, x, y); err != nil {
 		return nil, err
 	}
-	zr, err := zlib.NewReader(bytes.NewReader(blob))
+	ms, err := mbt.acquire()
 	if err != nil {
 		return nil, err
 	}
-	gd := make(map[string]*json.RawMessage)
-	if err = json.NewDecoder(zr).Decode(&gd); err != nil {
-		return nil, err
-	}
-	rows, err = mbt.gridDataStmt.Query(z, x, y)
+	defer ms.release()
+	rows, err := ms.tileStmt.QueryContext(ctx, z, x, y)
 	if err != nil {
-		return nil, err
+		return nil, tileerr(err, z, x, y)
 	}
 	defer rows.Close()
-	var data bytes.Buffer
-	sep := ""
-	data.WriteString("{")
-	for rows.Next() {
-		var key_name, key_json string
-		if err = rows.Scan(&key_name, &key_json); err != nil {
-			break
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, tileerr(err, z, x, y)
 		}
-		data.WriteString(sep + `"` + key_name + `":` + key_json)
-		sep = ","
+		return nil, ms.notfound(z, x, y)
+	}
+	var blob []byte
+	if err = rows.Scan(&blob); err != nil {
+		return nil, tileerr(err, z, x, y)
 	}
-	data.WriteString("}")
-	datamsg := json.RawMessage(data.Bytes())
-	gd["data"] = &datamsg
+	return blob, nil
+}
 
-	if callback != "" {
-		var final bytes.Buffer
-		final.WriteString(callback + "(")
-		err = json.NewEncoder(&final).Encode(gd)
-		final.WriteString(");")
-		return final.Bytes(), err
+// GetGridData returns the UTFGrid of tile z, x, y in TMS coordinates
+// in JSON format, or in JSONP format if callback is not empty.
+func (mbt *Map) GetGridData(z, x, y int, callback string) ([]byte, error) {
+	return mbt.GetGridContext(context.Background(), z, x, y, callback)
+}
+
+// GetGridContext returns the UTFGrid of tile z, x, y in TMS coordinates
+// like GetGridData. The queries are interrupted when ctx is done.
+func (mbt *Map) GetGridContext(ctx context.Context, z, x, y int, callback string) ([]byte, error) {
+	g, err := mbt.grid(ctx, z, x, y)
+	if err != nil {
+		return nil, err
 	}
+	return g.Encode(callback)
+}
 
-	return json.Marshal(gd)
+// ModTime returns the modification time of the file
+// when it was last loaded.
+func (mbt *Map) ModTime() time.Time {
+	mbt.mtx.RLock()
+	defer mbt.mtx.RUnlock()
+	return mbt.mtime
 }
 
 func (mbt *Map) Metadata() *Metadata {
+	mbt.mtx.RLock()
+	defer mbt.mtx.RUnlock()
 	return mbt.metadata
 }
+
+// HasGrids reports if the file has UTFGrid tables.
+func (mbt *Map) HasGrids() bool {
+	mbt.mtx.RLock()
+	defer mbt.mtx.RUnlock()
+	return mbt.ms != nil && mbt.ms.gridStmt != nil
+}
+
+// Schema reports the table layout of the underlying file.
+func (mbt *Map) Schema() Schema {
+	mbt.mtx.RLock()
+	defer mbt.mtx.RUnlock()
+	if mbt.ms == nil {
+		return FlatSchema
+	}
+	return mbt.ms.schema
+}
@@ -0,0 +1,128 @@
+package mbtiles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tempFile returns the name of a file in a new temporary directory,
+// and a function to remove the directory.
+func tempFile(tb testing.TB, name string) (string, func()) {
+	dir, err := ioutil.TempDir("", "mbtiles")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return filepath.Join(dir, name), func() { os.RemoveAll(dir) }
+}
+
+// testTile returns the content of tile z, x, y in files
+// created by writeTestFile with version v.
+func testTile(v, z, x, y int) []byte {
+	return []byte(fmt.Sprintf("tile %d/%d/%d v%d", z, x, y, v))
+}
+
+// writeTestFile creates fn with all tiles up to maxz.
+func writeTestFile(tb testing.TB, fn string, maxz, v int) {
+	w, err := Create(fn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for z := 0; z <= maxz; z++ {
+		for x := 0; x < 1<<uint(z); x++ {
+			for y := 0; y < 1<<uint(z); y++ {
+				if err = w.PutTile(z, x, y, testTile(v, z, x, y)); err != nil {
+					tb.Fatal(err)
+				}
+			}
+		}
+	}
+	if err = w.SetMetadata("name", fmt.Sprint("v", v)); err != nil {
+		tb.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// openTestFile opens a new file with all tiles up to maxz.
+// The returned function removes the file.
+func openTestFile(tb testing.TB, maxz int) (*Map, func()) {
+	fn, cleanup := tempFile(tb, "test.mbtiles")
+	writeTestFile(tb, fn, maxz, 1)
+	mbt, err := Open(fn)
+	if err != nil {
+		cleanup()
+		tb.Fatal(err)
+	}
+	return mbt, cleanup
+}
+
+// TestCloseWaitsForIterators checks that Close blocks until open
+// iterators are closed. An iterator that is never closed makes
+// Close block forever.
+func TestCloseWaitsForIterators(t *testing.T) {
+	mbt, cleanup := openTestFile(t, 2)
+	defer cleanup()
+	it := mbt.Tiles(nil)
+	if !it.Next() {
+		t.Fatal("no tiles:", it.Err())
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- mbt.Close() }()
+	select {
+	case <-done:
+		t.Fatal("Close returned with an open iterator")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the iterator is still usable
+	if !it.Next() {
+		t.Fatal("iteration stopped:", it.Err())
+	}
+	it.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the iterator was closed")
+	}
+	if _, err := mbt.GetTile(0, 0, 0); err != ErrClosed {
+		t.Fatalf("GetTile after Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+// BenchmarkGetTileParallel measures the throughput of
+// concurrent reads with increasing parallelism.
+func BenchmarkGetTileParallel(b *testing.B) {
+	const maxz = 6
+	mbt, cleanup := openTestFile(b, maxz)
+	defer cleanup()
+	defer mbt.Close()
+	for _, p := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("p%d", p), func(b *testing.B) {
+			b.SetParallelism(p)
+			var seed int64
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(atomic.AddInt64(&seed, 7919))
+				for pb.Next() {
+					z := i % (maxz + 1)
+					n := 1 << uint(z)
+					x, y := i/7%n, i/3%n
+					if _, err := mbt.GetTile(z, x, y); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package mbtiles
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -14,12 +17,92 @@ type MbtBounds struct {
 type MbtCenter struct {
 	Lon, Lat, Zoom float64
 }
+
+// Metadata is the content of the metadata table of an MBTiles file.
+// Keys defined by the MBTiles 1.3 specification and the common TileMill
+// extensions are parsed into the typed fields, the rest are kept in Extra.
+//
+// Values returns the metadata in key/value form. Keys with unchanged
+// values are returned exactly as they were parsed.
 type Metadata struct {
 	Bounds                                                    MbtBounds
 	Center                                                    MbtCenter
 	MinZoom, MaxZoom                                          int
 	Name, Description, Attribution, Legend, Template, Version string
-	Errors                                                    []error
+	Format                                                    string
+	Type                                                      string // "overlay" or "baselayer"
+	Scheme                                                    string // "tms" or "xyz"
+
+	// VectorLayers and Tilestats are parsed from the json key,
+	// its other members are kept in JsonExtra.
+	VectorLayers []VectorLayer
+	Tilestats    json.RawMessage
+	JsonExtra    map[string]json.RawMessage
+
+	// Extra holds the keys not listed above.
+	Extra map[string]string
+
+	Errors []error
+
+	raw    map[string]string // values parsed
+	parsed map[string]string // values parsed, formatted like in Values
+}
+
+// VectorLayer describes a layer of vector tiles.
+type VectorLayer struct {
+	Id          string            `json:"id"`
+	Fields      map[string]string `json:"fields"`
+	Description string            `json:"description,omitempty"`
+	MinZoom     int               `json:"minzoom"`
+	MaxZoom     int               `json:"maxzoom"`
+}
+
+// metadata key names with typed fields
+const (
+	keyBounds      = "bounds"
+	keyCenter      = "center"
+	keyMinZoom     = "minzoom"
+	keyMaxZoom     = "maxzoom"
+	keyName        = "name"
+	keyDescription = "description"
+	keyAttribution = "attribution"
+	keyLegend      = "legend"
+	keyTemplate    = "template"
+	keyVersion     = "version"
+	keyFormat      = "format"
+	keyType        = "type"
+	keyScheme      = "scheme"
+	keyJson        = "json"
+)
+
+var metadataKeys = []string{
+	keyName, keyFormat, keyBounds, keyCenter, keyMinZoom, keyMaxZoom,
+	keyAttribution, keyDescription, keyType, keyVersion, keyScheme,
+	keyLegend, keyTemplate, keyJson,
+}
+
+func (md *Metadata) stringField(name string) *string {
+	switch name {
+	case keyName:
+		return &md.Name
+	case keyDescription:
+		return &md.Description
+	case keyAttribution:
+		return &md.Attribution
+	case keyLegend:
+		return &md.Legend
+	case keyTemplate:
+		return &md.Template
+	case keyVersion:
+		return &md.Version
+	case keyFormat:
+		return &md.Format
+	case keyType:
+		return &md.Type
+	case keyScheme:
+		return &md.Scheme
+	}
+	return nil
 }
 
 func mbtMetadata(db *sql.DB) (*Metadata, error) {
@@ -29,40 +112,189 @@ func mbtMetadata(db *sql.DB) (*Metadata, error) {
 	}
 	defer rows.Close()
 
-	md := new(Metadata)
+	values := make(map[string]string)
 	for rows.Next() {
 		var name, value string
 		err = rows.Scan(&name, &value)
 		if err != nil {
 			return nil, err
 		}
+		values[name] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ParseMetadata(values), nil
+}
+
+// ParseMetadata parses metadata from key/value pairs.
+// Malformed values are reported in the Errors field.
+func ParseMetadata(values map[string]string) *Metadata {
+	md := &Metadata{
+		raw:    make(map[string]string),
+		parsed: make(map[string]string),
+	}
+	for name, value := range values {
 		var ve []error
 		switch name {
-		case "bounds":
+		case keyBounds:
 			ve = fill(value, &md.Bounds.W, &md.Bounds.S, &md.Bounds.E, &md.Bounds.N)
-		case "center":
-			ve = fill(value, &md.Center.Lat, &md.Center.Lon, &md.Center.Zoom)
-		case "minzoom":
+		case keyCenter:
+			ve = fill(value, &md.Center.Lon, &md.Center.Lat, &md.Center.Zoom)
+		case keyMinZoom:
 			ve = fill(value, &md.MinZoom)
-		case "maxzoom":
+		case keyMaxZoom:
 			ve = fill(value, &md.MaxZoom)
+		case keyJson:
+			ve = md.parseJson(value)
 		default:
-			if rv := reflect.ValueOf(md).Elem().FieldByName(strings.Title(name)); rv.Kind() == reflect.String {
-				rv.SetString(value)
+			if p := md.stringField(name); p != nil {
+				*p = value
+			} else {
+				if md.Extra == nil {
+					md.Extra = make(map[string]string)
+				}
+				md.Extra[name] = value
+				continue
 			}
 		}
-		if ve != nil {
-			md.Errors = append(md.Errors, ve...)
+		for _, err := range ve {
+			md.Errors = append(md.Errors, &MetadataError{name, err})
+		}
+		md.raw[name] = value
+	}
+	for name := range md.raw {
+		md.parsed[name] = md.format(name)
+	}
+	return md
+}
+
+func (md *Metadata) parseJson(value string) []error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return []error{err}
+	}
+	var ve []error
+	if v, ok := m["vector_layers"]; ok {
+		if err := json.Unmarshal(v, &md.VectorLayers); err != nil {
+			ve = append(ve, err)
+		}
+		delete(m, "vector_layers")
+	}
+	if v, ok := m["tilestats"]; ok {
+		md.Tilestats = v
+		delete(m, "tilestats")
+	}
+	if len(m) != 0 {
+		md.JsonExtra = m
+	}
+	return ve
+}
+
+func formatFloats(v ...float64) string {
+	s := make([]string, len(v))
+	for i, f := range v {
+		s[i] = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strings.Join(s, ",")
+}
+
+// format returns the value of the typed field for key name,
+// or "" if the field is empty.
+func (md *Metadata) format(name string) string {
+	switch name {
+	case keyBounds:
+		if md.Bounds == (MbtBounds{}) {
+			return ""
+		}
+		return formatFloats(md.Bounds.W, md.Bounds.S, md.Bounds.E, md.Bounds.N)
+	case keyCenter:
+		if md.Center == (MbtCenter{}) {
+			return ""
+		}
+		return formatFloats(md.Center.Lon, md.Center.Lat, md.Center.Zoom)
+	case keyMinZoom:
+		if md.MinZoom == 0 && md.MaxZoom == 0 {
+			return ""
 		}
+		return strconv.Itoa(md.MinZoom)
+	case keyMaxZoom:
+		if md.MaxZoom == 0 {
+			return ""
+		}
+		return strconv.Itoa(md.MaxZoom)
+	case keyJson:
+		return md.formatJson()
+	}
+	if p := md.stringField(name); p != nil {
+		return *p
 	}
-	return md, nil
+	return ""
+}
+
+func (md *Metadata) formatJson() string {
+	m := make(map[string]interface{})
+	for k, v := range md.JsonExtra {
+		m[k] = v
+	}
+	if md.VectorLayers != nil {
+		m["vector_layers"] = md.VectorLayers
+	}
+	if md.Tilestats != nil {
+		m["tilestats"] = md.Tilestats
+	}
+	if len(m) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Values returns the metadata as key/value pairs
+// to be stored in the metadata table.
+func (md *Metadata) Values() map[string]string {
+	values := make(map[string]string)
+	for k, v := range md.Extra {
+		values[k] = v
+	}
+	for _, name := range metadataKeys {
+		v := md.format(name)
+		if raw, ok := md.raw[name]; ok && md.parsed[name] == v {
+			values[name] = raw
+		} else if v != "" || ok {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// MetadataError records a malformed metadata value.
+type MetadataError struct {
+	Key string
+	Err error
+}
+
+func (e *MetadataError) Error() string {
+	return "metadata " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *MetadataError) Unwrap() error {
+	return e.Err
 }
 
 func fill(s string, v ...interface{}) []error {
-	ve := make([]error, 0, len(v))
 	parts := strings.Split(s, ",")
+	if len(parts) != len(v) {
+		return []error{fmt.Errorf("expected %d comma separated values, got %d", len(v), len(parts))}
+	}
+	ve := make([]error, 0, len(v))
 	for i := range v {
-		part, rv := parts[i], reflect.ValueOf(v[i]).Elem()
+		part, rv := strings.TrimSpace(parts[i]), reflect.ValueOf(v[i]).Elem()
 		switch rv.Kind() {
 		case reflect.Float32, reflect.Float64:
 			fval, err := strconv.ParseFloat(part, 64)
@@ -0,0 +1,107 @@
+package mbtiles
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	values := map[string]string{
+		"name":    "test",
+		"format":  "pbf",
+		"bounds":  "-180, -85.05112878,180,85.05112878",
+		"center":  "19.04,47.5,6",
+		"minzoom": "0",
+		"maxzoom": "14",
+		"json":    `{"vector_layers":[{"id":"roads","fields":{"class":"String"},"minzoom":0,"maxzoom":14}],"other":[1, 2]}`,
+		"custom":  "kept",
+	}
+	md := ParseMetadata(values)
+	if len(md.Errors) != 0 {
+		t.Fatalf("errors: %v", md.Errors)
+	}
+	if want := (MbtBounds{W: -180, S: -85.05112878, E: 180, N: 85.05112878}); md.Bounds != want {
+		t.Errorf("bounds: got %+v, want %+v", md.Bounds, want)
+	}
+	if want := (MbtCenter{Lon: 19.04, Lat: 47.5, Zoom: 6}); md.Center != want {
+		t.Errorf("center: got %+v, want %+v", md.Center, want)
+	}
+	if md.MinZoom != 0 || md.MaxZoom != 14 {
+		t.Errorf("zoom: got %d-%d", md.MinZoom, md.MaxZoom)
+	}
+	if len(md.VectorLayers) != 1 || md.VectorLayers[0].Id != "roads" {
+		t.Errorf("vector layers: got %+v", md.VectorLayers)
+	}
+	if string(md.JsonExtra["other"]) != "[1, 2]" {
+		t.Errorf("json extra: got %q", md.JsonExtra)
+	}
+	if md.Extra["custom"] != "kept" {
+		t.Errorf("extra: got %v", md.Extra)
+	}
+
+	// unchanged values are kept verbatim
+	if got := md.Values(); !reflect.DeepEqual(got, values) {
+		t.Errorf("values:\ngot  %v\nwant %v", got, values)
+	}
+
+	md.Bounds.W = -10
+	md.MaxZoom = 12
+	md.Description = "new"
+	got := md.Values()
+	want := map[string]string{
+		"bounds":      "-10,-85.05112878,180,85.05112878",
+		"maxzoom":     "12",
+		"description": "new",
+		"minzoom":     "0",
+		"center":      "19.04,47.5,6",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("changed values: %s is %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(values)+1 {
+		t.Errorf("changed values: got %d keys, want %d", len(got), len(values)+1)
+	}
+}
+
+func TestMetadataNew(t *testing.T) {
+	md := &Metadata{Name: "new", Format: "png", MaxZoom: 3}
+	want := map[string]string{"name": "new", "format": "png", "minzoom": "0", "maxzoom": "3"}
+	if got := md.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("values: got %v, want %v", got, want)
+	}
+}
+
+func TestMetadataError(t *testing.T) {
+	tests := []struct {
+		key, value string
+		n          int // number of errors
+	}{
+		{"bounds", "-180,-85,180", 1},
+		{"bounds", "-180,-85,east,north", 2},
+		{"center", "", 1},
+		{"center", "19.04,47.5,zoom", 1},
+		{"minzoom", "1.5", 1},
+		{"json", "{", 1},
+		{"json", `{"vector_layers":{}}`, 1},
+	}
+	for _, tt := range tests {
+		md := ParseMetadata(map[string]string{tt.key: tt.value})
+		if len(md.Errors) != tt.n {
+			t.Errorf("%s %q: got errors %v, want %d", tt.key, tt.value, md.Errors, tt.n)
+			continue
+		}
+		for _, err := range md.Errors {
+			var me *MetadataError
+			if !errors.As(err, &me) || me.Key != tt.key {
+				t.Errorf("%s %q: got error %v, want MetadataError", tt.key, tt.value, err)
+			}
+		}
+		// malformed values are preserved
+		if got := md.Values()[tt.key]; got != tt.value {
+			t.Errorf("%s %q: got value %q", tt.key, tt.value, got)
+		}
+	}
+}
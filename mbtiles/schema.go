@@ -0,0 +1,162 @@
+package mbtiles
+
+import (
+	"database/sql"
+)
+
+// Schema identifies the table layout of an MBTiles file.
+type Schema int
+
+const (
+	// FlatSchema stores tiles in the "tiles" table directly.
+	FlatSchema Schema = iota
+
+	// DedupSchema is the layout used by TileMill and mb-util,
+	// where the "map" table references tile contents in the
+	// "images" table by tile_id, and "tiles" is a view.
+	// Identical tiles are stored only once.
+	DedupSchema
+)
+
+func (s Schema) String() string {
+	switch s {
+	case FlatSchema:
+		return "flat"
+	case DedupSchema:
+		return "dedup"
+	}
+	return "unknown"
+}
+
+const flatSchemaSQL = `
+create table if not exists metadata (name text, value text);
+create unique index if not exists name on metadata (name);
+create table if not exists tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob);
+create unique index if not exists tile_index on tiles (zoom_level, tile_column, tile_row);
+create table if not exists grids (zoom_level integer, tile_column integer, tile_row integer, grid blob);
+create unique index if not exists grid_index on grids (zoom_level, tile_column, tile_row);
+create table if not exists grid_data (zoom_level integer, tile_column integer, tile_row integer, key_name text, key_json text);
+create unique index if not exists grid_data_index on grid_data (zoom_level, tile_column, tile_row, key_name);
+`
+
+const dedupSchemaSQL = `
+create table if not exists metadata (name text, value text);
+create unique index if not exists name on metadata (name);
+create table if not exists map (zoom_level integer, tile_column integer, tile_row integer, tile_id text, grid_id text);
+create unique index if not exists map_index on map (zoom_level, tile_column, tile_row);
+create table if not exists images (tile_data blob, tile_id text);
+create unique index if not exists images_id on images (tile_id);
+create table if not exists grid_utfgrid (grid_id text, grid_utfgrid blob);
+create unique index if not exists grid_utfgrid_lookup on grid_utfgrid (grid_id);
+create table if not exists grid_key (grid_id text, key_name text);
+create unique index if not exists grid_key_lookup on grid_key (grid_id, key_name);
+create table if not exists keymap (key_name text, key_json text);
+create unique index if not exists keymap_lookup on keymap (key_name);
+create view if not exists tiles as
+	select map.zoom_level as zoom_level, map.tile_column as tile_column,
+		map.tile_row as tile_row, images.tile_data as tile_data
+	from map join images on images.tile_id = map.tile_id;
+create view if not exists grids as
+	select map.zoom_level as zoom_level, map.tile_column as tile_column,
+		map.tile_row as tile_row, grid_utfgrid.grid_utfgrid as grid
+	from map join grid_utfgrid on grid_utfgrid.grid_id = map.grid_id;
+create view if not exists grid_data as
+	select map.zoom_level as zoom_level, map.tile_column as tile_column,
+		map.tile_row as tile_row, keymap.key_name as key_name, keymap.key_json as key_json
+	from map join grid_key on grid_key.grid_id = map.grid_id
+		join keymap on keymap.key_name = grid_key.key_name;
+`
+
+func (s Schema) sql() string {
+	if s == DedupSchema {
+		return dedupSchemaSQL
+	}
+	return flatSchemaSQL
+}
+
+// dbObjects returns the tables and views in db
+// as a map from name to type.
+func dbObjects(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(`select name, type from sqlite_master
+where type in ('table', 'view')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err = rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		m[name] = typ
+	}
+	return m, rows.Err()
+}
+
+func hasTables(objs map[string]string, names ...string) bool {
+	for _, n := range names {
+		if objs[n] != "table" {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlQueries holds the statements to read tiles and grids
+// from a database.
+type sqlQueries struct {
+	schema           Schema
+	tile, grid, data string // grid and data are empty if there are no grids
+
+	// tiles lists zoom_level, tile_column, tile_row and tile_data of all tiles,
+	// sizes has the length of tile_data in place of the data,
+	// zooms yields the zoom level range.
+	tiles, sizes, zooms string
+}
+
+// detectSchema inspects db and returns the queries
+// to read it. In deduplicated files the underlying tables
+// are queried directly instead of the views, so sqlite
+// may use their indices.
+func detectSchema(db *sql.DB) (*sqlQueries, error) {
+	objs, err := dbObjects(db)
+	if err != nil {
+		return nil, err
+	}
+	q := new(sqlQueries)
+	if hasTables(objs, "map", "images") {
+		q.schema = DedupSchema
+		q.tile = `select images.tile_data from map
+join images on images.tile_id = map.tile_id
+where map.zoom_level = ?1 and map.tile_column = ?2 and map.tile_row = ?3`
+		q.tiles = `select map.zoom_level, map.tile_column, map.tile_row, images.tile_data from map
+join images on images.tile_id = map.tile_id`
+		q.sizes = `select map.zoom_level, map.tile_column, map.tile_row, length(images.tile_data) from map
+join images on images.tile_id = map.tile_id`
+		q.zooms = `select min(zoom_level), max(zoom_level) from map where tile_id is not null`
+	} else {
+		q.schema = FlatSchema
+		q.tile = `select tile_data from tiles
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`
+		q.tiles = `select zoom_level, tile_column, tile_row, tile_data from tiles`
+		q.sizes = `select zoom_level, tile_column, tile_row, length(tile_data) from tiles`
+		q.zooms = `select min(zoom_level), max(zoom_level) from tiles`
+	}
+	switch {
+	case hasTables(objs, "map", "grid_utfgrid", "grid_key", "keymap"):
+		q.grid = `select grid_utfgrid.grid_utfgrid from map
+join grid_utfgrid on grid_utfgrid.grid_id = map.grid_id
+where map.zoom_level = ?1 and map.tile_column = ?2 and map.tile_row = ?3`
+		q.data = `select keymap.key_name, keymap.key_json from map
+join grid_key on grid_key.grid_id = map.grid_id
+join keymap on keymap.key_name = grid_key.key_name
+where map.zoom_level = ?1 and map.tile_column = ?2 and map.tile_row = ?3`
+	case objs["grids"] != "" && objs["grid_data"] != "":
+		q.grid = `select grid from grids
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`
+		q.data = `select key_name,key_json from grid_data
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`
+	}
+	return q, nil
+}
@@ -0,0 +1,63 @@
+package mbtiles
+
+import (
+	"context"
+	"time"
+)
+
+// TileSource provides tiles and metadata of a tileset.
+// Map implements TileSource, other backends may be served
+// by implementing it. Tile rows are in TMS order.
+//
+// GetTile and GetGridData return ErrTileNotFound or an error
+// matching it with errors.Is for missing tiles, and ErrClosed after
+// the source is closed. Implementations must be safe for concurrent use.
+type TileSource interface {
+	GetTile(z, x, y int) ([]byte, error)
+
+	// GetGridData returns the UTFGrid of the tile with its key data
+	// in JSON format, or in JSONP format if callback is not empty.
+	GetGridData(z, x, y int, callback string) ([]byte, error)
+
+	Metadata() *Metadata
+
+	// ModTime returns the time the tileset was last modified.
+	ModTime() time.Time
+}
+
+// GridSource is a TileSource providing structured UTFGrids.
+type GridSource interface {
+	TileSource
+	GetGrid(z, x, y int) (*Grid, error)
+}
+
+// ContextTileSource is a TileSource with lookups
+// that can be cancelled through a context.
+type ContextTileSource interface {
+	TileSource
+	GetTileContext(ctx context.Context, z, x, y int) ([]byte, error)
+	GetGridContext(ctx context.Context, z, x, y int, callback string) ([]byte, error)
+}
+
+// GetTileContext returns a tile from src using ctx
+// if src is a ContextTileSource.
+func GetTileContext(ctx context.Context, src TileSource, z, x, y int) ([]byte, error) {
+	if cs, ok := src.(ContextTileSource); ok {
+		return cs.GetTileContext(ctx, z, x, y)
+	}
+	return src.GetTile(z, x, y)
+}
+
+// GetGridContext returns a grid from src using ctx
+// if src is a ContextTileSource.
+func GetGridContext(ctx context.Context, src TileSource, z, x, y int, callback string) ([]byte, error) {
+	if cs, ok := src.(ContextTileSource); ok {
+		return cs.GetGridContext(ctx, z, x, y, callback)
+	}
+	return src.GetGridData(z, x, y, callback)
+}
+
+var (
+	_ ContextTileSource = (*Map)(nil)
+	_ GridSource        = (*Map)(nil)
+)
@@ -0,0 +1,126 @@
+package mbtiles
+
+// SizeBuckets are the upper limits of the tile size histogram
+// buckets in Stats. The last histogram bucket counts tiles
+// larger than the last limit.
+var SizeBuckets = []int{
+	1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 32 << 10,
+	64 << 10, 128 << 10, 256 << 10, 512 << 10, 1 << 20,
+}
+
+// ZoomStats are statistics of tiles in a single zoom level.
+type ZoomStats struct {
+	Zoom             int
+	Count            int
+	Bytes            int64
+	MinSize, MaxSize int
+
+	// Tile extent in TMS coordinates and its lon/lat bounds.
+	MinCol, MinRow, MaxCol, MaxRow int
+	Bounds                         MbtBounds
+
+	// Outside is the number of tiles outside the declared bounds.
+	Outside int
+
+	// Histogram has tile counts for the size limits in SizeBuckets.
+	Histogram []int
+}
+
+// Stats are statistics of the tiles in a Map.
+type Stats struct {
+	Count int
+	Bytes int64
+	Zooms []*ZoomStats // in ascending zoom order
+
+	// Histogram is the size histogram of all tiles.
+	Histogram []int
+}
+
+func sizeBucket(size int) int {
+	for i, lim := range SizeBuckets {
+		if size <= lim {
+			return i
+		}
+	}
+	return len(SizeBuckets)
+}
+
+// Stats computes statistics of the tiles in mbt.
+// Only the tile sizes are read, not the data.
+func (mbt *Map) Stats() (*Stats, error) {
+	ms, err := mbt.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer ms.release()
+	rows, err := ms.db.Query(ms.queries.sizes + `
+order by zoom_level`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	md := ms.metadata
+	hasbounds := md.has(keyBounds) && !md.malformed(keyBounds)
+	var bx0, by0, bx1, by1 int
+
+	st := &Stats{Histogram: make([]int, len(SizeBuckets)+1)}
+	var zs *ZoomStats
+	for rows.Next() {
+		var z, x, y, size int
+		if err = rows.Scan(&z, &x, &y, &size); err != nil {
+			return nil, err
+		}
+		if zs == nil || zs.Zoom != z {
+			zs = &ZoomStats{
+				Zoom:      z,
+				MinSize:   size,
+				MaxSize:   size,
+				MinCol:    x,
+				MaxCol:    x,
+				MinRow:    y,
+				MaxRow:    y,
+				Histogram: make([]int, len(SizeBuckets)+1),
+			}
+			st.Zooms = append(st.Zooms, zs)
+			if hasbounds {
+				bx0, by0, bx1, by1 = tileRange(md.Bounds, z)
+			}
+		}
+		if hasbounds && (x < bx0 || x > bx1 || y < by0 || y > by1) {
+			zs.Outside++
+		}
+		zs.Count++
+		zs.Bytes += int64(size)
+		zs.MinSize = imin(zs.MinSize, size)
+		zs.MaxSize = imax(zs.MaxSize, size)
+		zs.MinCol, zs.MaxCol = imin(zs.MinCol, x), imax(zs.MaxCol, x)
+		zs.MinRow, zs.MaxRow = imin(zs.MinRow, y), imax(zs.MaxRow, y)
+		b := sizeBucket(size)
+		zs.Histogram[b]++
+		st.Histogram[b]++
+		st.Count++
+		st.Bytes += int64(size)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, zs := range st.Zooms {
+		zs.Bounds = tileBounds(zs.Zoom, zs.MinCol, zs.MinRow, zs.MaxCol, zs.MaxRow)
+	}
+	return st, nil
+}
+
+func imin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package mbtiles
+
+import (
+	"strconv"
+)
+
+// TileJSONVersion is the version of TileJSON documents created by TileJSON.
+const TileJSONVersion = "3.0.0"
+
+// TileJSON is a TileJSON 3.0.0 document.
+type TileJSON struct {
+	TileJSON     string        `json:"tilejson"`
+	Tiles        []string      `json:"tiles"`
+	VectorLayers []VectorLayer `json:"vector_layers,omitempty"`
+	Attribution  string        `json:"attribution,omitempty"`
+	Bounds       []float64     `json:"bounds,omitempty"`
+	Center       []float64     `json:"center,omitempty"` // lon, lat, zoom
+	Description  string        `json:"description,omitempty"`
+	FillZoom     *int          `json:"fillzoom,omitempty"`
+	Grids        []string      `json:"grids,omitempty"`
+	Legend       string        `json:"legend,omitempty"`
+	MaxZoom      int           `json:"maxzoom"`
+	MinZoom      int           `json:"minzoom"`
+	Name         string        `json:"name,omitempty"`
+	Scheme       string        `json:"scheme"`
+	Template     string        `json:"template,omitempty"`
+	Version      string        `json:"version"`
+
+	// Format is not part of the specification,
+	// but it is used by many clients.
+	Format string `json:"format,omitempty"`
+}
+
+// TileJSON returns the TileJSON document of a tileset with md
+// served at the tiles and grids URL templates. URL templates
+// use XYZ rows. The fill zoom is taken from the fillzoom metadata key.
+func (md *Metadata) TileJSON(tiles, grids []string) *TileJSON {
+	tj := &TileJSON{
+		TileJSON:     TileJSONVersion,
+		Tiles:        tiles,
+		Grids:        grids,
+		VectorLayers: md.VectorLayers,
+		Attribution:  md.Attribution,
+		Description:  md.Description,
+		Legend:       md.Legend,
+		MinZoom:      md.MinZoom,
+		MaxZoom:      md.MaxZoom,
+		Name:         md.Name,
+		Scheme:       "xyz",
+		Template:     md.Template,
+		Version:      md.Version,
+		Format:       md.Format,
+	}
+	if !md.has(keyMaxZoom) {
+		tj.MaxZoom = 30
+	}
+	if tj.Version == "" {
+		tj.Version = "1.0.0"
+	}
+	if md.has(keyBounds) && !md.malformed(keyBounds) {
+		b := md.Bounds
+		tj.Bounds = []float64{b.W, b.S, b.E, b.N}
+	}
+	if md.has(keyCenter) && !md.malformed(keyCenter) {
+		c := md.Center
+		tj.Center = []float64{c.Lon, c.Lat, c.Zoom}
+	}
+	if s, ok := md.Extra["fillzoom"]; ok {
+		if z, err := strconv.Atoi(s); err == nil {
+			tj.FillZoom = &z
+		}
+	}
+	return tj
+}
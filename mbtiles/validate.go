@@ -0,0 +1,181 @@
+package mbtiles
+
+import (
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/tile"
+)
+
+// Severity tells how serious a Finding is.
+type Severity int
+
+const (
+	// Warning is a deviation from recommendations of the specification.
+	Warning Severity = iota
+
+	// Error is a violation of the specification that
+	// may prevent clients from using the tileset.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding is a single problem found during validation.
+type Finding struct {
+	Severity Severity
+	Key      string // metadata key concerned, if any
+	Message  string
+}
+
+func (f Finding) String() string {
+	if f.Key != "" {
+		return f.Severity.String() + ": " + f.Key + ": " + f.Message
+	}
+	return f.Severity.String() + ": " + f.Message
+}
+
+// Report is the result of a validation.
+type Report []Finding
+
+// HasErrors reports if r has findings with Error severity.
+func (r Report) HasErrors() bool {
+	for _, f := range r {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(sev Severity, key, format string, args ...interface{}) {
+	*r = append(*r, Finding{sev, key, fmt.Sprintf(format, args...)})
+}
+
+// has reports if key is present in md.
+func (md *Metadata) has(key string) bool {
+	if md.raw != nil {
+		_, ok := md.raw[key]
+		return ok
+	}
+	return md.format(key) != ""
+}
+
+// malformed reports if md has a parse error for key
+func (md *Metadata) malformed(key string) bool {
+	for _, err := range md.Errors {
+		if e, ok := err.(*MetadataError); ok && e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks md against the MBTiles 1.3 specification.
+func (md *Metadata) Validate() Report {
+	var r Report
+
+	for _, err := range md.Errors {
+		if e, ok := err.(*MetadataError); ok {
+			r.add(Error, e.Key, "malformed value: %v", e.Err)
+		} else {
+			r.add(Error, "", "%v", err)
+		}
+	}
+
+	for _, key := range []string{keyName, keyFormat} {
+		if !md.has(key) {
+			r.add(Error, key, "missing required key")
+		}
+	}
+	for _, key := range []string{keyBounds, keyCenter, keyMinZoom, keyMaxZoom} {
+		if !md.has(key) {
+			r.add(Warning, key, "missing recommended key")
+		}
+	}
+
+	format := md.TileFormat()
+	if md.has(keyFormat) && format == UnknownFormat {
+		r.add(Warning, keyFormat, "unknown format %q", md.Format)
+	}
+	if format == PBF && len(md.VectorLayers) == 0 && !md.malformed(keyJson) {
+		r.add(Error, keyJson, "vector tilesets must list vector_layers")
+	}
+	if md.has(keyType) && md.Type != "overlay" && md.Type != "baselayer" {
+		r.add(Warning, keyType, "type must be overlay or baselayer, got %q", md.Type)
+	}
+
+	zoomok := md.has(keyMinZoom) && md.has(keyMaxZoom) &&
+		!md.malformed(keyMinZoom) && !md.malformed(keyMaxZoom)
+	if md.has(keyMinZoom) && md.MinZoom < 0 {
+		r.add(Error, keyMinZoom, "negative zoom %d", md.MinZoom)
+	}
+	if zoomok && md.MinZoom > md.MaxZoom {
+		r.add(Error, keyMinZoom, "minzoom %d greater than maxzoom %d", md.MinZoom, md.MaxZoom)
+		zoomok = false
+	}
+
+	b := md.Bounds
+	boundsok := md.has(keyBounds) && !md.malformed(keyBounds)
+	if boundsok {
+		if b.W < -180 || b.E > 180 || b.S < -90 || b.N > 90 {
+			r.add(Error, keyBounds, "outside WGS84 range")
+			boundsok = false
+		} else if b.S < -tile.MaxLat || b.N > tile.MaxLat {
+			r.add(Warning, keyBounds, "latitude outside Web Mercator range ±%v", tile.MaxLat)
+		}
+		if b.W > b.E {
+			r.add(Error, keyBounds, "west %v greater than east %v", b.W, b.E)
+			boundsok = false
+		}
+		if b.S > b.N {
+			r.add(Error, keyBounds, "south %v greater than north %v", b.S, b.N)
+			boundsok = false
+		}
+	}
+
+	c := md.Center
+	if md.has(keyCenter) && !md.malformed(keyCenter) {
+		if c.Lon < -180 || c.Lon > 180 || c.Lat < -90 || c.Lat > 90 {
+			r.add(Error, keyCenter, "outside WGS84 range")
+		} else if boundsok && (c.Lon < b.W || c.Lon > b.E || c.Lat < b.S || c.Lat > b.N) {
+			r.add(Error, keyCenter, "outside bounds")
+		}
+		if zoomok && (c.Zoom < float64(md.MinZoom) || c.Zoom > float64(md.MaxZoom)) {
+			r.add(Warning, keyCenter, "zoom %v outside zoom range %d-%d", c.Zoom, md.MinZoom, md.MaxZoom)
+		}
+	}
+
+	return r
+}
+
+// Validate checks the metadata of mbt against the MBTiles 1.3 specification,
+// and compares the declared zoom range with the zoom levels of the tiles.
+func (mbt *Map) Validate() (Report, error) {
+	ms, err := mbt.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer ms.release()
+	md := ms.metadata
+	r := md.Validate()
+
+	minz, maxz, ok, err := ms.zoomRange()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		r.add(Warning, "", "no tiles")
+		return r, nil
+	}
+	if md.has(keyMinZoom) && md.MinZoom != minz {
+		r.add(Error, keyMinZoom, "is %d, but lowest zoom level of tiles is %d", md.MinZoom, minz)
+	}
+	if md.has(keyMaxZoom) && md.MaxZoom != maxz {
+		r.add(Error, keyMaxZoom, "is %d, but highest zoom level of tiles is %d", md.MaxZoom, maxz)
+	}
+	return r, nil
+}
@@ -0,0 +1,120 @@
+package mbtiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := map[string]string{
+		"name":    "valid",
+		"format":  "png",
+		"bounds":  "-10,-20,30,40",
+		"center":  "0,0,2",
+		"minzoom": "0",
+		"maxzoom": "4",
+	}
+	type finding struct {
+		sev Severity
+		key string
+	}
+	tests := []struct {
+		name string
+		set  map[string]string // changes to valid, "" removes the key
+		want []finding
+	}{
+		{"valid", nil, nil},
+		{"required", map[string]string{"name": "", "format": ""},
+			[]finding{{Error, "name"}, {Error, "format"}}},
+		{"recommended", map[string]string{"bounds": "", "center": "", "minzoom": "", "maxzoom": ""},
+			[]finding{{Warning, "bounds"}, {Warning, "center"}, {Warning, "minzoom"}, {Warning, "maxzoom"}}},
+		{"malformed", map[string]string{"bounds": "-10,-20,30"},
+			[]finding{{Error, "bounds"}}},
+		{"unknown format", map[string]string{"format": "tiff"},
+			[]finding{{Warning, "format"}}},
+		{"vector layers", map[string]string{"format": "pbf"},
+			[]finding{{Error, "json"}}},
+		{"type", map[string]string{"type": "underlay"},
+			[]finding{{Warning, "type"}}},
+		{"negative zoom", map[string]string{"minzoom": "-1"},
+			[]finding{{Error, "minzoom"}}},
+		{"zoom order", map[string]string{"minzoom": "5"},
+			[]finding{{Error, "minzoom"}}},
+		{"wgs84", map[string]string{"bounds": "-190,-20,30,40"},
+			[]finding{{Error, "bounds"}}},
+		{"mercator", map[string]string{"bounds": "-10,-89,30,40"},
+			[]finding{{Warning, "bounds"}}},
+		{"west east", map[string]string{"bounds": "30,-20,-10,40"},
+			[]finding{{Error, "bounds"}}},
+		{"south north", map[string]string{"bounds": "-10,40,30,-20"},
+			[]finding{{Error, "bounds"}}},
+		{"center outside bounds", map[string]string{"center": "35,0,2"},
+			[]finding{{Error, "center"}}},
+		{"center outside wgs84", map[string]string{"center": "0,95,2"},
+			[]finding{{Error, "center"}}},
+		{"center zoom", map[string]string{"center": "0,0,5"},
+			[]finding{{Warning, "center"}}},
+	}
+	for _, tt := range tests {
+		values := make(map[string]string)
+		for k, v := range valid {
+			values[k] = v
+		}
+		for k, v := range tt.set {
+			if v == "" {
+				delete(values, k)
+			} else {
+				values[k] = v
+			}
+		}
+		r := ParseMetadata(values).Validate()
+		var got []finding
+		for _, f := range r {
+			got = append(got, finding{f.Severity, f.Key})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, r, tt.want)
+		}
+		wantErrors := false
+		for _, f := range tt.want {
+			wantErrors = wantErrors || f.sev == Error
+		}
+		if r.HasErrors() != wantErrors {
+			t.Errorf("%s: HasErrors is %v", tt.name, r.HasErrors())
+		}
+	}
+}
+
+func TestMapValidate(t *testing.T) {
+	fn, cleanup := tempFile(t, "validate.mbtiles")
+	defer cleanup()
+	writeTestFile(t, fn, 2, 1)
+	w, err := OpenWriter(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := &Metadata{Name: "validate", Format: "png", MinZoom: 1, MaxZoom: 3,
+		Bounds: MbtBounds{W: -180, S: -85, E: 180, N: 85}, Center: MbtCenter{Zoom: 2}}
+	if err = w.WriteMetadata(md); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	r, err := mbt.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{
+		{Error, "minzoom", "is 1, but lowest zoom level of tiles is 0"},
+		{Error, "maxzoom", "is 3, but highest zoom level of tiles is 2"},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got %v, want %v", r, want)
+	}
+}
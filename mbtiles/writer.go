@@ -0,0 +1,428 @@
+package mbtiles
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+var errNoGrids = errors.New("mbtiles: file has no grid tables")
+
+// DefaultBatchSize is the number of modifications a Writer
+// collects in a single transaction before committing it.
+const DefaultBatchSize = 1000
+
+// Writer creates and populates an MBTiles file.
+// Like Map, it uses TMS tile coordinates.
+//
+// Modifications are batched into transactions of BatchSize
+// operations, use Flush to commit pending changes early.
+// If a modification fails, the pending changes are rolled back
+// and all later calls return the same error.
+// A Writer must not be used from multiple goroutines.
+type Writer struct {
+	Filename  string
+	BatchSize int
+
+	schema  Schema
+	nogrids bool // the file has no grid tables
+	db      *sql.DB
+	tx      *sql.Tx
+	n       int
+	err     error // sticky error of a failed modification
+
+	stmts []*sql.Stmt
+
+	metaStmt *sql.Stmt
+
+	// FlatSchema
+	tileStmt, gridStmt, gridDelStmt, gridDataDelStmt, gridDataStmt *sql.Stmt
+
+	// DedupSchema
+	imageStmt, mapTileStmt, utfgridStmt, gridKeyStmt, keymapStmt, keyDataStmt, mapGridStmt, mapGridDelStmt *sql.Stmt
+}
+
+// Create creates a new MBTiles file with the standard schema.
+// It fails if fn already exists.
+func Create(fn string) (*Writer, error) {
+	return CreateSchema(fn, FlatSchema)
+}
+
+// CreateSchema creates a new MBTiles file using the specified schema.
+// It fails if fn already exists.
+//
+// With DedupSchema, tiles and grids are stored keyed by the MD5 hash
+// of their content, therefore identical tiles take up space only once.
+// Grid key data is shared between all tiles in a deduplicated file,
+// storing different data for a key already present fails with
+// ErrKeyConflict.
+func CreateSchema(fn string, schema Schema) (*Writer, error) {
+	if _, err := os.Stat(fn); err == nil {
+		return nil, &os.PathError{Op: "create", Path: fn, Err: os.ErrExist}
+	}
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(schema.sql()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return newWriter(fn, db, schema, false)
+}
+
+// OpenWriter opens the existing MBTiles file fn for modification.
+// Tiles are added using the schema of the file, no tables are created.
+// PutGrid fails if the file has no grid tables.
+func OpenWriter(fn string) (*Writer, error) {
+	if _, err := os.Stat(fn); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		return nil, err
+	}
+	q, err := detectSchema(db)
+	var objs map[string]string
+	if err == nil {
+		objs, err = dbObjects(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	var nogrids bool
+	if q.schema == DedupSchema {
+		nogrids = !hasTables(objs, "grid_utfgrid", "grid_key", "keymap")
+	} else {
+		nogrids = !hasTables(objs, "grids", "grid_data")
+	}
+	return newWriter(fn, db, q.schema, nogrids)
+}
+
+// Copy writes a consistent copy of the MBTiles file src to dst,
+// including changes still in its write-ahead log. The file dst
+// must not exist or must be empty.
+func Copy(dst, src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", readonlyDSN(src))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`vacuum into ?1`, dst)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func newWriter(fn string, db *sql.DB, schema Schema, nogrids bool) (*Writer, error) {
+	w := &Writer{Filename: fn, BatchSize: DefaultBatchSize, schema: schema, nogrids: nogrids, db: db}
+	if err := w.init(); err != nil {
+		w.closeStmts()
+		db.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) init() error {
+	var err error
+	prep := func(s **sql.Stmt, q string) {
+		if err == nil {
+			*s, err = w.db.Prepare(q)
+			if err == nil {
+				w.stmts = append(w.stmts, *s)
+			}
+		}
+	}
+	prep(&w.metaStmt, `insert or replace into metadata (name, value) values (?1, ?2)`)
+	if w.schema == DedupSchema {
+		prep(&w.imageStmt, `insert or ignore into images (tile_id, tile_data) values (?1, ?2)`)
+		prep(&w.mapTileStmt, `insert into map
+(zoom_level, tile_column, tile_row, tile_id) values (?1, ?2, ?3, ?4)
+on conflict (zoom_level, tile_column, tile_row) do update set tile_id = excluded.tile_id`)
+		if !w.nogrids {
+			prep(&w.utfgridStmt, `insert or ignore into grid_utfgrid (grid_id, grid_utfgrid) values (?1, ?2)`)
+			prep(&w.gridKeyStmt, `insert or ignore into grid_key (grid_id, key_name) values (?1, ?2)`)
+			prep(&w.keymapStmt, `insert or ignore into keymap (key_name, key_json) values (?1, ?2)`)
+			prep(&w.keyDataStmt, `select key_json from keymap where key_name = ?1`)
+			prep(&w.mapGridStmt, `insert into map
+(zoom_level, tile_column, tile_row, grid_id) values (?1, ?2, ?3, ?4)
+on conflict (zoom_level, tile_column, tile_row) do update set grid_id = excluded.grid_id`)
+			prep(&w.mapGridDelStmt, `update map set grid_id = null
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
+		}
+		return err
+	}
+	prep(&w.tileStmt, `insert or replace into tiles
+(zoom_level, tile_column, tile_row, tile_data) values (?1, ?2, ?3, ?4)`)
+	if !w.nogrids {
+		prep(&w.gridStmt, `insert or replace into grids
+(zoom_level, tile_column, tile_row, grid) values (?1, ?2, ?3, ?4)`)
+		prep(&w.gridDelStmt, `delete from grids
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
+		prep(&w.gridDataDelStmt, `delete from grid_data
+where zoom_level = ?1 and tile_column = ?2 and tile_row = ?3`)
+		prep(&w.gridDataStmt, `insert or replace into grid_data
+(zoom_level, tile_column, tile_row, key_name, key_json) values (?1, ?2, ?3, ?4, ?5)`)
+	}
+	return err
+}
+
+func (w *Writer) closeStmts() {
+	for _, s := range w.stmts {
+		s.Close()
+	}
+	w.stmts = nil
+}
+
+// HasGrids reports if the file has UTFGrid tables.
+func (w *Writer) HasGrids() bool {
+	return !w.nogrids
+}
+
+// Schema reports the table layout used by w.
+func (w *Writer) Schema() Schema {
+	return w.schema
+}
+
+func contentID(data []byte) string {
+	h := md5.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+// exec runs stmt within the current batch transaction.
+func (w *Writer) exec(stmt *sql.Stmt, args ...interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			return w.fail(err)
+		}
+		w.tx = tx
+	}
+	if _, err := w.tx.Stmt(stmt).Exec(args...); err != nil {
+		return w.fail(err)
+	}
+	return nil
+}
+
+// fail rolls back the current batch, and makes err sticky.
+func (w *Writer) fail(err error) error {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx = nil
+	}
+	w.n = 0
+	w.err = err
+	return err
+}
+
+// done counts a finished modification and commits
+// the transaction when the batch is full.
+func (w *Writer) done() error {
+	w.n++
+	if w.n >= w.BatchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// PutTile stores data as the tile at z, x, y, replacing
+// any existing tile with the same coordinates.
+func (w *Writer) PutTile(z, x, y int, data []byte) error {
+	if w.schema == DedupSchema {
+		id := contentID(data)
+		if err := w.exec(w.imageStmt, id, data); err != nil {
+			return err
+		}
+		if err := w.exec(w.mapTileStmt, z, x, y, id); err != nil {
+			return err
+		}
+		return w.done()
+	}
+	if err := w.exec(w.tileStmt, z, x, y, data); err != nil {
+		return err
+	}
+	return w.done()
+}
+
+// PutGrid stores an UTFGrid for the tile at z, x, y.
+// Grid is the uncompressed grid JSON having the "grid" and "keys" members,
+// data holds the JSON objects of the grid keys.
+func (w *Writer) PutGrid(z, x, y int, grid []byte, data map[string]json.RawMessage) error {
+	if w.nogrids {
+		return errNoGrids
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(grid); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if w.schema == DedupSchema {
+		return w.putDedupGrid(z, x, y, buf.Bytes(), data)
+	}
+	if err := w.exec(w.gridStmt, z, x, y, buf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.exec(w.gridDataDelStmt, z, x, y); err != nil {
+		return err
+	}
+	for k, v := range data {
+		if err := w.exec(w.gridDataStmt, z, x, y, k, string(v)); err != nil {
+			return err
+		}
+	}
+	return w.done()
+}
+
+// DeleteGrid removes the UTFGrid of the tile at z, x, y, if any.
+func (w *Writer) DeleteGrid(z, x, y int) error {
+	if w.nogrids {
+		return nil
+	}
+	if w.schema == DedupSchema {
+		if err := w.exec(w.mapGridDelStmt, z, x, y); err != nil {
+			return err
+		}
+		return w.done()
+	}
+	if err := w.exec(w.gridDelStmt, z, x, y); err != nil {
+		return err
+	}
+	if err := w.exec(w.gridDataDelStmt, z, x, y); err != nil {
+		return err
+	}
+	return w.done()
+}
+
+// gridID returns the content id of a grid with its key data.
+func gridID(blob []byte, data map[string]json.RawMessage) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := md5.New()
+	h.Write(blob)
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (w *Writer) putDedupGrid(z, x, y int, blob []byte, data map[string]json.RawMessage) error {
+	id := gridID(blob, data)
+	if err := w.exec(w.utfgridStmt, id, blob); err != nil {
+		return err
+	}
+	for k, v := range data {
+		if err := w.exec(w.gridKeyStmt, id, k); err != nil {
+			return err
+		}
+		if err := w.exec(w.keymapStmt, k, string(v)); err != nil {
+			return err
+		}
+		var stored string
+		if err := w.tx.Stmt(w.keyDataStmt).QueryRow(k).Scan(&stored); err != nil {
+			return w.fail(err)
+		}
+		if stored != string(v) {
+			return w.fail(fmt.Errorf("%w: %q", ErrKeyConflict, k))
+		}
+	}
+	if err := w.exec(w.mapGridStmt, z, x, y, id); err != nil {
+		return err
+	}
+	return w.done()
+}
+
+// SetMetadata sets the metadata value for name.
+func (w *Writer) SetMetadata(name, value string) error {
+	if err := w.exec(w.metaStmt, name, value); err != nil {
+		return err
+	}
+	return w.done()
+}
+
+// WriteMetadata stores all values of md.
+func (w *Writer) WriteMetadata(md *Metadata) error {
+	values := md.Values()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := w.SetMetadata(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush commits pending modifications.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.n = 0
+	if w.tx == nil {
+		return nil
+	}
+	tx := w.tx
+	w.tx = nil
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Close commits pending modifications and closes the database.
+// Nothing is committed if w has failed.
+// With DedupSchema, contents no longer referenced by any tile are
+// removed before closing.
+func (w *Writer) Close() error {
+	err := w.Flush()
+	if err == nil && w.schema == DedupSchema {
+		err = w.purge()
+	}
+	w.closeStmts()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// purge deletes images and grids not referenced from the map table.
+func (w *Writer) purge() error {
+	if w.nogrids {
+		_, err := w.db.Exec(`delete from images where tile_id not in (select tile_id from map where tile_id is not null)`)
+		return err
+	}
+	_, err := w.db.Exec(`
+delete from images where tile_id not in (select tile_id from map where tile_id is not null);
+delete from grid_utfgrid where grid_id not in (select grid_id from map where grid_id is not null);
+delete from grid_key where grid_id not in (select grid_id from grid_utfgrid);`)
+	return err
+}
@@ -0,0 +1,326 @@
+package mbtiles
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testGrid = &Grid{
+	Grid: []string{" !", "!#"},
+	Keys: []string{"", "a", "b"},
+	Data: map[string]json.RawMessage{
+		"a": json.RawMessage(`{"name":"a"}`),
+		"b": json.RawMessage(`{"name":"b"}`),
+	},
+}
+
+// execSQL runs the statements q on the database fn.
+func execSQL(t *testing.T, fn, q string) {
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(q)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for _, schema := range []Schema{FlatSchema, DedupSchema} {
+		t.Run(schema.String(), func(t *testing.T) {
+			fn, cleanup := tempFile(t, "writer.mbtiles")
+			defer cleanup()
+			w, err := CreateSchema(fn, schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.PutTile(1, 0, 1, testTile(1, 1, 0, 1)); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.PutTile(1, 1, 1, testTile(1, 1, 0, 1)); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.PutTile(1, 1, 1, testTile(2, 1, 1, 1)); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteGrid(1, 0, 1, testGrid); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.SetMetadata("name", "writer"); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			mbt, err := Open(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mbt.Close()
+			if got := mbt.Schema(); got != schema {
+				t.Errorf("schema: got %v, want %v", got, schema)
+			}
+			checkTile(t, mbt, 1, 1, 0, 1)
+			checkTile(t, mbt, 2, 1, 1, 1) // replaced
+			if _, err := mbt.GetTile(1, 0, 0); err != ErrTileNotFound {
+				t.Errorf("missing tile: got %v, want %v", err, ErrTileNotFound)
+			}
+			g, err := mbt.GetGrid(1, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, testGrid) {
+				t.Errorf("grid: got %+v, want %+v", g, testGrid)
+			}
+			if _, err := mbt.GetGrid(1, 1, 1); err != ErrTileNotFound {
+				t.Errorf("missing grid: got %v, want %v", err, ErrTileNotFound)
+			}
+			if name := mbt.Metadata().Name; name != "writer" {
+				t.Errorf("metadata name: got %q", name)
+			}
+		})
+	}
+}
+
+func TestWriterBatch(t *testing.T) {
+	fn, cleanup := tempFile(t, "batch.mbtiles")
+	defer cleanup()
+	w, err := Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.BatchSize = 2
+
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	has := func(z, x, y int) bool {
+		_, err := mbt.GetTile(z, x, y)
+		return err == nil
+	}
+	if err = w.PutTile(0, 0, 0, testTile(1, 0, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if has(0, 0, 0) {
+		t.Fatal("tile committed before the batch is full")
+	}
+	if err = w.PutTile(1, 0, 0, testTile(1, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !has(0, 0, 0) || !has(1, 0, 0) {
+		t.Fatal("full batch not committed")
+	}
+	if err = w.PutTile(1, 1, 0, testTile(1, 1, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !has(1, 1, 0) {
+		t.Fatal("tile not committed by Flush")
+	}
+}
+
+func TestWriterStickyError(t *testing.T) {
+	fn, cleanup := tempFile(t, "sticky.mbtiles")
+	defer cleanup()
+	writeTestFile(t, fn, 0, 1)
+	execSQL(t, fn, `create trigger fail before insert on tiles when new.zoom_level = 9
+begin select raise(abort, 'no zoom 9'); end`)
+
+	w, err := OpenWriter(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.PutTile(1, 0, 0, testTile(1, 1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.PutTile(1, 1, 0, testTile(1, 1, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	failure := w.PutTile(9, 0, 0, []byte("fails"))
+	if failure == nil {
+		t.Fatal("PutTile succeeded despite the trigger")
+	}
+	if err = w.PutTile(1, 1, 1, testTile(1, 1, 1, 1)); err != failure {
+		t.Errorf("PutTile after failure: got %v, want %v", err, failure)
+	}
+	if err = w.SetMetadata("name", "failed"); err != failure {
+		t.Errorf("SetMetadata after failure: got %v, want %v", err, failure)
+	}
+	if err = w.Flush(); err != failure {
+		t.Errorf("Flush after failure: got %v, want %v", err, failure)
+	}
+	if err = w.Close(); err != failure {
+		t.Errorf("Close after failure: got %v, want %v", err, failure)
+	}
+
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	checkTile(t, mbt, 1, 1, 0, 0) // flushed before the failure
+	for _, c := range [][3]int{{1, 1, 0}, {1, 1, 1}} {
+		if data, err := mbt.GetTile(c[0], c[1], c[2]); err != ErrTileNotFound {
+			t.Errorf("tile %v of failed batch: got %q, %v", c, data, err)
+		}
+	}
+	if name := mbt.Metadata().Name; name != "v1" {
+		t.Errorf("metadata name: got %q, want v1", name)
+	}
+}
+
+func TestOpenWriterNoGrids(t *testing.T) {
+	fn, cleanup := tempFile(t, "nogrids.mbtiles")
+	defer cleanup()
+	writeTestFile(t, fn, 0, 1)
+	execSQL(t, fn, `drop table grids; drop table grid_data`)
+	w, err := OpenWriter(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.HasGrids() {
+		t.Error("HasGrids of file without grid tables")
+	}
+	if err = w.WriteGrid(0, 0, 0, testGrid); err != errNoGrids {
+		t.Errorf("WriteGrid: got %v, want %v", err, errNoGrids)
+	}
+	if err = w.PutTile(0, 0, 0, testTile(2, 0, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	checkTile(t, mbt, 2, 0, 0, 0)
+}
+
+// count returns the number of rows in table of the database fn.
+func count(t *testing.T, fn, table string) int {
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err = db.QueryRow(`select count(*) from ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDedup(t *testing.T) {
+	fn, cleanup := tempFile(t, "dedup.mbtiles")
+	defer cleanup()
+	w, err := CreateSchema(fn, DedupSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := [][]byte{[]byte("land"), []byte("sea"), []byte("coast")}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			if err = w.PutTile(2, x, y, blobs[(x+y)%len(blobs)]); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteGrid(2, x, y, testGrid); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// the only tile with the grid without key data
+	if err = w.PutGrid(2, 0, 0, []byte(`{"grid":[" "],"keys":[""]}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.PutTile(0, 0, 0, []byte("unique")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.PutTile(0, 0, 0, blobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.DeleteGrid(2, 3, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		table string
+		n     int
+	}{
+		{"map", 17},
+		{"images", 3}, // unique is no longer referenced
+		{"grid_utfgrid", 2},
+		{"grid_key", 2},
+		{"keymap", 2},
+	} {
+		if n := count(t, fn, tt.table); n != tt.n {
+			t.Errorf("%s: got %d rows, want %d", tt.table, n, tt.n)
+		}
+	}
+
+	mbt, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	if data, err := mbt.GetTile(2, 1, 2); err != nil || string(data) != "land" {
+		t.Errorf("tile 2/1/2: got %q, %v", data, err)
+	}
+	if g, err := mbt.GetGrid(2, 1, 1); err != nil || !reflect.DeepEqual(g, testGrid) {
+		t.Errorf("grid 2/1/1: got %+v, %v", g, err)
+	}
+	if g, err := mbt.GetGrid(2, 0, 0); err != nil || g.Data != nil {
+		t.Errorf("grid 2/0/0: got %+v, %v", g, err)
+	}
+	if _, err := mbt.GetGrid(2, 3, 3); err != ErrTileNotFound {
+		t.Errorf("deleted grid: got %v, want %v", err, ErrTileNotFound)
+	}
+}
+
+func TestDedupKeyConflict(t *testing.T) {
+	fn, cleanup := tempFile(t, "conflict.mbtiles")
+	defer cleanup()
+	w, err := CreateSchema(fn, DedupSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteGrid(0, 0, 0, testGrid); err != nil {
+		t.Fatal(err)
+	}
+	// same key data in another grid is fine
+	same := &Grid{Grid: []string{"!"}, Keys: []string{"", "a"}, Data: map[string]json.RawMessage{"a": testGrid.Data["a"]}}
+	if err = w.WriteGrid(1, 0, 0, same); err != nil {
+		t.Fatal(err)
+	}
+	other := &Grid{Grid: []string{"!"}, Keys: []string{"", "a"}, Data: map[string]json.RawMessage{"a": json.RawMessage(`{"name":"other"}`)}}
+	err = w.WriteGrid(1, 1, 0, other)
+	if !errors.Is(err, ErrKeyConflict) {
+		t.Fatalf("conflicting key data: got %v, want %v", err, ErrKeyConflict)
+	}
+	if err2 := w.PutTile(1, 1, 1, []byte("tile")); err2 != err {
+		t.Errorf("PutTile after conflict: got %v, want %v", err2, err)
+	}
+	if err2 := w.Close(); err2 != err {
+		t.Errorf("Close after conflict: got %v, want %v", err2, err)
+	}
+}
@@ -0,0 +1,358 @@
+// Package merge combines MBTiles files.
+package merge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/mvt"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPolicy means that the policy can't combine the tiles of the files,
+// such as Composite with vector tiles.
+var ErrPolicy = errors.New("merge: policy does not apply to the tiles")
+
+// Policy decides the content of tiles present in several files.
+type Policy int
+
+const (
+	FirstWins Policy = iota // keep the tile added first
+	LastWins                // replace it with the tile added last
+	Composite               // alpha-composite raster tiles, later ones on top
+	Concat                  // concatenate the layers of vector tiles
+)
+
+var policyNames = []string{"first", "last", "composite", "concat"}
+
+func (p Policy) String() string {
+	if p >= 0 && int(p) < len(policyNames) {
+		return policyNames[p]
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the policy named by s, one of
+// "first", "last", "composite" or "concat".
+func ParsePolicy(s string) (Policy, error) {
+	for i, n := range policyNames {
+		if strings.EqualFold(s, n) {
+			return Policy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("merge: unknown policy %q", s)
+}
+
+// check returns an error wrapping ErrPolicy if p can't combine
+// tiles of mbt. Files of unknown format are accepted.
+func (p Policy) check(mbt *mbtiles.Map) error {
+	f := tileFormat(mbt)
+	switch {
+	case f == mbtiles.UnknownFormat:
+	case p == Composite && f == mbtiles.PBF:
+		return fmt.Errorf("%w: %v needs raster tiles, %s has %v", ErrPolicy, p, mbt.Filename, f)
+	case p == Concat && f != mbtiles.PBF:
+		return fmt.Errorf("%w: %v needs vector tiles, %s has %v", ErrPolicy, p, mbt.Filename, f)
+	}
+	return nil
+}
+
+// tileFormat returns the format of the tiles of mbt
+// declared in the metadata or detected from its first tile.
+func tileFormat(mbt *mbtiles.Map) mbtiles.TileFormat {
+	if f := mbt.Metadata().TileFormat(); f != mbtiles.UnknownFormat {
+		return f
+	}
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	if !it.Next() {
+		return mbtiles.UnknownFormat
+	}
+	_, _, _, data := it.Tile()
+	f, _ := mbtiles.DetectFormat(data)
+	return f
+}
+
+// resolve returns the tile replacing old when data is added with the same
+// coordinates, or false if old should be kept. Composite tiles are encoded
+// in format f, transparent ones are not stored in JPEG files.
+func (p Policy) resolve(f mbtiles.TileFormat, old, data []byte) ([]byte, bool, error) {
+	switch p {
+	case FirstWins:
+		return nil, false, nil
+	case LastWins:
+		return data, true, nil
+	case Composite:
+		out, err := raster.BlendFormat(f, old, data)
+		return out, err == nil && out != nil, err
+	case Concat:
+		out, err := concat(old, data)
+		return out, err == nil, err
+	}
+	return nil, false, fmt.Errorf("merge: invalid policy %v", p)
+}
+
+// Options control Merge and Patch.
+type Options struct {
+	Policy Policy
+
+	// Schema is the schema of the file created by Merge.
+	Schema mbtiles.Schema
+}
+
+// Report summarizes a merge.
+type Report struct {
+	Tiles     int `json:"tiles"`     // tiles read
+	Added     int `json:"added"`     // tiles not present before
+	Conflicts int `json:"conflicts"` // tiles present already
+	Replaced  int `json:"replaced"`  // conflicting tiles written according to the policy
+
+	// GridsSkipped is set if grids were not copied
+	// because the destination has no grid tables.
+	GridsSkipped bool `json:"grids_skipped,omitempty"`
+}
+
+// Merge creates the MBTiles file dst from the tiles, grids and metadata of
+// the files srcs. Tiles in several files are resolved using opt.Policy in
+// the order of srcs, an error wrapping ErrPolicy is returned if the
+// policy does not apply to their tiles. Bounds and zoom ranges of the
+// metadata are merged, other metadata values are taken from the first
+// file having them. The file dst is removed if Merge fails.
+//
+// Composite tiles are encoded in the format declared by the result, so
+// files with WebP tiles can't be composited. In JPEG files, conflicting
+// tiles are kept unchanged if their composite is transparent.
+//
+// UTFGrids follow the tiles: a tile kept or replaced keeps or replaces
+// its grid, also when the replacing tile has no grid. Tiles combined by
+// Composite or Concat have no grid.
+func Merge(dst string, srcs []string, opt *Options) (*Report, error) {
+	if opt == nil {
+		opt = new(Options)
+	}
+	w, err := mbtiles.CreateSchema(dst, opt.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+	r := new(Report)
+	for _, src := range srcs {
+		if err = apply(dst, dst, src, opt.Policy, r); err != nil {
+			os.Remove(dst)
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Patch applies the tiles, grids and metadata of the MBTiles file patch
+// onto the existing file dst, like Merge with dst as first source.
+//
+// The patch is applied to a copy of dst in the same directory that
+// replaces dst when complete, so dst is either patched entirely or left
+// unchanged. Readers having dst open see the old content until they
+// reopen it.
+func Patch(dst, patch string, opt *Options) (*Report, error) {
+	if opt == nil {
+		opt = new(Options)
+	}
+	dfi, err := os.Stat(dst)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	f.Close()
+	r := new(Report)
+	err = mbtiles.Copy(tmp, dst)
+	if err == nil {
+		err = apply(tmp, dst, patch, opt.Policy, r)
+	}
+	if err == nil {
+		err = os.Chmod(tmp, dfi.Mode())
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return r, nil
+}
+
+// apply adds the content of src to the tiles of base using p,
+// and writes the result to dst. Base and dst may be the same file.
+func apply(dst, base, src string, p Policy, r *Report) error {
+	sfi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	bfi, err := os.Stat(base)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(sfi, bfi) {
+		return fmt.Errorf("merge: %s is the destination", src)
+	}
+
+	in, err := mbtiles.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	cur, err := mbtiles.Open(base)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	w, err := mbtiles.OpenWriter(dst)
+	if err != nil {
+		return err
+	}
+	err = applyTiles(w, cur, in, p, r)
+	if err == nil {
+		err = w.WriteMetadata(mergeMetadata(cur.Metadata(), in.Metadata()))
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func applyTiles(w *mbtiles.Writer, cur, in *mbtiles.Map, p Policy, r *Report) error {
+	for _, mbt := range []*mbtiles.Map{cur, in} {
+		if err := p.check(mbt); err != nil {
+			return err
+		}
+	}
+	// the format of the result, see mergeMetadata
+	f := tileFormat(cur)
+	if f == mbtiles.UnknownFormat {
+		f = tileFormat(in)
+	}
+	if p == Composite && f != mbtiles.UnknownFormat && !raster.CanEncode(f) {
+		return fmt.Errorf("%w: %v can't write %v tiles", ErrPolicy, p, f)
+	}
+	if f == mbtiles.UnknownFormat {
+		f = mbtiles.PNG
+	}
+	grids, oldgrids := in.HasGrids(), cur.HasGrids()
+	if grids && !w.HasGrids() {
+		grids, r.GridsSkipped = false, true
+	}
+	it := in.Tiles(nil)
+	defer it.Close()
+	for it.Next() {
+		z, x, y, data := it.Tile()
+		r.Tiles++
+		old, err := cur.GetTile(z, x, y)
+		added := err != nil
+		switch {
+		case errors.Is(err, mbtiles.ErrTileNotFound):
+			r.Added++
+		case err != nil:
+			return err
+		default:
+			r.Conflicts++
+			var ok bool
+			if data, ok, err = p.resolve(f, old, data); err != nil {
+				return fmt.Errorf("merge: tile %d/%d/%d: %w", z, x, y, err)
+			}
+			if !ok {
+				continue
+			}
+			r.Replaced++
+		}
+		if err = w.PutTile(z, x, y, data); err != nil {
+			return err
+		}
+		switch {
+		case added && grids, !added && p == LastWins && (grids || oldgrids):
+			err = copyGrid(in, w, z, x, y)
+		case !added && oldgrids:
+			// neither grid describes a combined tile
+			err = w.DeleteGrid(z, x, y)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// copyGrid replaces the grid of tile z, x, y in w with the one in src,
+// or deletes it if src has none.
+func copyGrid(src *mbtiles.Map, w *mbtiles.Writer, z, x, y int) error {
+	g, err := src.GetGrid(z, x, y)
+	if errors.Is(err, mbtiles.ErrTileNotFound) {
+		return w.DeleteGrid(z, x, y)
+	}
+	if err != nil {
+		return err
+	}
+	return w.WriteGrid(z, x, y, g)
+}
+
+// concat returns the vector tile with the layers of tiles a and b,
+// gzip compressed if either of them is compressed.
+func concat(a, b []byte) ([]byte, error) {
+	fa, ca := mbtiles.DetectFormat(a)
+	fb, cb := mbtiles.DetectFormat(b)
+	if fa != mbtiles.PBF && fa != mbtiles.UnknownFormat || fb != mbtiles.PBF && fb != mbtiles.UnknownFormat {
+		return nil, fmt.Errorf("%w: concat needs vector tiles", ErrPolicy)
+	}
+	da, err := decompress(a, ca)
+	if err != nil {
+		return nil, err
+	}
+	db, err := decompress(b, cb)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mvt.Concat(da, db)
+	if err != nil || ca == mbtiles.Uncompressed && cb == mbtiles.Uncompressed {
+		return data, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(blob []byte, c mbtiles.Compression) ([]byte, error) {
+	var r io.Reader
+	var err error
+	switch c {
+	case mbtiles.Gzip:
+		r, err = gzip.NewReader(bytes.NewReader(blob))
+	case mbtiles.Zlib:
+		r, err = zlib.NewReader(bytes.NewReader(blob))
+	default:
+		return blob, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
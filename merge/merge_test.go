@@ -0,0 +1,384 @@
+package merge
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/mvt"
+	"github.com/tajtiattila/go-mbtiles/raster"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// tempDir creates a temporary directory
+// and returns a function to remove it.
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+var (
+	red  = color.NRGBA{255, 0, 0, 255}
+	blue = color.NRGBA{0, 0, 255, 128}
+)
+
+func pngTile(t *testing.T, c color.NRGBA) []byte {
+	im := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(im, im.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	data, err := raster.Encode(im, mbtiles.PNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func vectorTile(layer string) []byte {
+	f := &mvt.Feature{Type: mvt.Point}
+	f.SetPaths([][]mvt.Coord{{{X: 10, Y: 20}}})
+	l := &mvt.Layer{Version: 2, Name: layer, Extent: mvt.DefaultExtent, Features: []*mvt.Feature{f}}
+	return (&mvt.Tile{Layers: []*mvt.Layer{l}}).Encode()
+}
+
+// testGrid returns a grid with the single key k.
+func testGrid(k string) *mbtiles.Grid {
+	return &mbtiles.Grid{
+		Grid: []string{"!"},
+		Keys: []string{"", k},
+		Data: map[string]json.RawMessage{k: json.RawMessage(`{"name":"` + k + `"}`)},
+	}
+}
+
+type testTile struct {
+	z, x, y int
+	data    []byte
+	grid    string // key of the grid of the tile, if any
+}
+
+// writeFile creates fn with tiles in the standard schema.
+func writeFile(t *testing.T, fn, format string, tiles ...testTile) {
+	w, err := mbtiles.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tiles {
+		if err = w.PutTile(tt.z, tt.x, tt.y, tt.data); err != nil {
+			t.Fatal(err)
+		}
+		if tt.grid != "" {
+			if err = w.WriteGrid(tt.z, tt.x, tt.y, testGrid(tt.grid)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = w.SetMetadata("format", format); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openFile(t *testing.T, fn string) *mbtiles.Map {
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mbt
+}
+
+// gridKey returns the key of the grid of tile z, x, y in mbt,
+// or "" if it has no grid.
+func gridKey(t *testing.T, mbt *mbtiles.Map, z, x, y int) string {
+	g, err := mbt.GetGrid(z, x, y)
+	if errors.Is(err, mbtiles.ErrTileNotFound) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.KeyAt(0, 0, 256)
+}
+
+func TestPolicy(t *testing.T) {
+	a, b := pngTile(t, red), pngTile(t, blue)
+	composite, err := raster.Blend(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	va, vb := vectorTile("a"), vectorTile("b")
+	concat, err := mvt.Concat(va, vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		p         Policy
+		format    string
+		old, data []byte
+		want      []byte
+	}{
+		{FirstWins, "png", a, b, a},
+		{LastWins, "png", a, b, b},
+		{Composite, "png", a, b, composite},
+		{Concat, "pbf", va, vb, concat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.p.String(), func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+			writeFile(t, dst, tt.format, testTile{z: 1, x: 0, y: 0, data: tt.old})
+			writeFile(t, src, tt.format,
+				testTile{z: 1, x: 0, y: 0, data: tt.data},
+				testTile{z: 1, x: 1, y: 0, data: tt.data})
+			r, err := Patch(dst, src, &Options{Policy: tt.p})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Report{Tiles: 2, Added: 1, Conflicts: 1}
+			if tt.p != FirstWins {
+				want.Replaced = 1
+			}
+			if *r != want {
+				t.Errorf("report: got %+v, want %+v", *r, want)
+			}
+			mbt := openFile(t, dst)
+			defer mbt.Close()
+			if got, err := mbt.GetTile(1, 0, 0); err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("conflicting tile: got %v, %v", got, err)
+			}
+			if got, err := mbt.GetTile(1, 1, 0); err != nil || !bytes.Equal(got, tt.data) {
+				t.Errorf("added tile: got %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestCompositeJPEG(t *testing.T) {
+	im := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(im, im.Rect, image.NewUniform(red), image.Point{}, draw.Src)
+	a, err := raster.Encode(im, mbtiles.JPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+	clear := pngTile(t, color.NRGBA{})
+	writeFile(t, dst, "jpg", testTile{data: a}, testTile{z: 1, data: clear})
+	// an opaque and a transparent composite
+	writeFile(t, src, "jpg", testTile{data: pngTile(t, blue)}, testTile{z: 1, data: clear})
+	r, err := Patch(dst, src, &Options{Policy: Composite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Replaced != 1 {
+		t.Errorf("replaced: got %d, want 1", r.Replaced)
+	}
+	mbt := openFile(t, dst)
+	defer mbt.Close()
+	data, err := mbt.GetTile(0, 0, 0)
+	if f, _ := mbtiles.DetectFormat(data); err != nil || f != mbtiles.JPEG {
+		t.Errorf("composite tile: got format %v, %v", f, err)
+	}
+	if got, err := mbt.GetTile(1, 0, 0); err != nil || !bytes.Equal(got, clear) {
+		t.Errorf("transparent composite replaced the tile")
+	}
+}
+
+func TestGrids(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Policy
+		old  string // grid of the conflicting tile in dst
+		data string // grid of the conflicting tile in src
+		want string
+	}{
+		{"keep", FirstWins, "a", "b", "a"},
+		{"keep without incoming", FirstWins, "a", "", "a"},
+		{"copy", LastWins, "a", "b", "b"},
+		{"copy to tile without grid", LastWins, "", "b", "b"},
+		{"delete", LastWins, "a", "", ""},
+		{"delete combined", Composite, "a", "b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := pngTile(t, red), pngTile(t, blue)
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+			writeFile(t, dst, "png", testTile{data: a, grid: tt.old})
+			writeFile(t, src, "png",
+				testTile{data: b, grid: tt.data},
+				testTile{z: 1, data: b, grid: "c"})
+			if _, err := Patch(dst, src, &Options{Policy: tt.p}); err != nil {
+				t.Fatal(err)
+			}
+			mbt := openFile(t, dst)
+			defer mbt.Close()
+			if got := gridKey(t, mbt, 0, 0, 0); got != tt.want {
+				t.Errorf("conflicting tile grid: got %q, want %q", got, tt.want)
+			}
+			if got := gridKey(t, mbt, 1, 0, 0); got != "c" {
+				t.Errorf("added tile grid: got %q, want %q", got, "c")
+			}
+		})
+	}
+}
+
+func TestGridsSkipped(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+	writeFile(t, dst, "png")
+	db, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`drop table grids; drop table grid_data`)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, src, "png", testTile{data: pngTile(t, red), grid: "a"})
+	r, err := Patch(dst, src, &Options{Policy: LastWins})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Added != 1 || !r.GridsSkipped {
+		t.Errorf("report: got %+v", *r)
+	}
+}
+
+func TestPolicyError(t *testing.T) {
+	tests := []struct {
+		p      Policy
+		format string
+		data   []byte
+	}{
+		{Composite, "pbf", vectorTile("a")},
+		{Concat, "png", pngTile(t, red)},
+		{Composite, "webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.p.String()+" "+tt.format, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+			writeFile(t, dst, tt.format, testTile{data: tt.data})
+			writeFile(t, src, tt.format, testTile{data: tt.data})
+			if _, err := Patch(dst, src, &Options{Policy: tt.p}); !errors.Is(err, ErrPolicy) {
+				t.Fatalf("got %v, want %v", err, ErrPolicy)
+			}
+		})
+	}
+}
+
+func TestPatchFailure(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	dst, src := filepath.Join(dir, "dst.mbtiles"), filepath.Join(dir, "src.mbtiles")
+	data := pngTile(t, red)
+	// a corrupt tile at the last zoom level fails the
+	// composite after more than a batch of tiles is added
+	const maxz = 5
+	writeFile(t, dst, "png", testTile{z: maxz, x: 31, y: 31, data: []byte("\x89PNG\r\n\x1a\nbad")})
+	var tiles []testTile
+	for z := 0; z <= maxz; z++ {
+		for x := 0; x < 1<<uint(z); x++ {
+			for y := 0; y < 1<<uint(z); y++ {
+				tiles = append(tiles, testTile{z: z, x: x, y: y, data: data})
+			}
+		}
+	}
+	if len(tiles) <= mbtiles.DefaultBatchSize {
+		t.Fatalf("%d tiles fit in a single batch", len(tiles))
+	}
+	writeFile(t, src, "png", tiles...)
+
+	before, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Patch(dst, src, &Options{Policy: Composite}); err == nil {
+		t.Fatal("Patch succeeded with a corrupt tile")
+	}
+	after, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("failed Patch modified the file")
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"dst.mbtiles", "src.mbtiles"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files after failed Patch: got %v, want %v", names, want)
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	a := mbtiles.ParseMetadata(map[string]string{
+		"name":    "a",
+		"format":  "pbf",
+		"bounds":  "-10,-5,10,5",
+		"minzoom": "2",
+		"maxzoom": "8",
+		"json":    `{"vector_layers":[{"id":"roads","fields":{"kind":"String"},"minzoom":2,"maxzoom":8}]}`,
+	})
+	b := mbtiles.ParseMetadata(map[string]string{
+		"name":        "b",
+		"description": "from b",
+		"bounds":      "0,-20,30,2",
+		"minzoom":     "0",
+		"maxzoom":     "6",
+		"json": `{"vector_layers":[` +
+			`{"id":"roads","description":"Roads","fields":{"kind":"String","lanes":"Number"},"minzoom":0,"maxzoom":10},` +
+			`{"id":"water","fields":{},"minzoom":0,"maxzoom":6}]}`,
+	})
+	md := mergeMetadata(a, b)
+	if len(md.Errors) != 0 {
+		t.Fatal(md.Errors)
+	}
+	if want := (mbtiles.MbtBounds{W: -10, S: -20, E: 30, N: 5}); md.Bounds != want {
+		t.Errorf("bounds: got %+v, want %+v", md.Bounds, want)
+	}
+	if md.MinZoom != 0 || md.MaxZoom != 8 {
+		t.Errorf("zoom: got %d-%d, want 0-8", md.MinZoom, md.MaxZoom)
+	}
+	if md.Name != "a" || md.Format != "pbf" || md.Description != "from b" {
+		t.Errorf("values: got name %q, format %q, description %q", md.Name, md.Format, md.Description)
+	}
+	want := []mbtiles.VectorLayer{
+		{Id: "roads", Description: "Roads", Fields: map[string]string{"kind": "String", "lanes": "Number"}, MinZoom: 0, MaxZoom: 10},
+		{Id: "water", Fields: map[string]string{}, MinZoom: 0, MaxZoom: 6},
+	}
+	if !reflect.DeepEqual(md.VectorLayers, want) {
+		t.Errorf("vector layers: got %+v, want %+v", md.VectorLayers, want)
+	}
+
+	// empty metadata is replaced
+	if md := mergeMetadata(mbtiles.ParseMetadata(nil), b); md != b {
+		t.Errorf("metadata of empty file: got %+v", md)
+	}
+}
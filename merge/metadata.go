@@ -0,0 +1,87 @@
+package merge
+
+import (
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"math"
+)
+
+// mergeMetadata returns the metadata of a file with the tiles of a and b.
+// Bounds and zoom ranges are extended, vector layers with the same id are
+// merged, other values of a take precedence. The metadata of b is used if
+// a is empty.
+func mergeMetadata(a, b *mbtiles.Metadata) *mbtiles.Metadata {
+	av, bv := a.Values(), b.Values()
+	if len(av) == 0 {
+		return b
+	}
+	values := make(map[string]string)
+	for k, v := range bv {
+		values[k] = v
+	}
+	for k, v := range av {
+		values[k] = v
+	}
+	md := mbtiles.ParseMetadata(values)
+
+	both := func(key string) bool {
+		return av[key] != "" && bv[key] != ""
+	}
+	if both("bounds") {
+		ab, bb := a.Bounds, b.Bounds
+		md.Bounds = mbtiles.MbtBounds{
+			N: math.Max(ab.N, bb.N),
+			S: math.Min(ab.S, bb.S),
+			E: math.Max(ab.E, bb.E),
+			W: math.Min(ab.W, bb.W),
+		}
+	}
+	if both("minzoom") && b.MinZoom < md.MinZoom {
+		md.MinZoom = b.MinZoom
+	}
+	if both("maxzoom") && b.MaxZoom > md.MaxZoom {
+		md.MaxZoom = b.MaxZoom
+	}
+	md.VectorLayers = mergeVectorLayers(a.VectorLayers, b.VectorLayers)
+	return md
+}
+
+// mergeVectorLayers returns the layers of a and b. Zoom ranges and
+// fields of layers with the same id are merged, descriptions of a are kept.
+func mergeVectorLayers(a, b []mbtiles.VectorLayer) []mbtiles.VectorLayer {
+	if b == nil {
+		return a
+	}
+	var layers []mbtiles.VectorLayer
+	index := make(map[string]int)
+	for _, v := range [][]mbtiles.VectorLayer{a, b} {
+		for _, l := range v {
+			i, ok := index[l.Id]
+			if !ok {
+				index[l.Id] = len(layers)
+				fields := make(map[string]string)
+				for k, f := range l.Fields {
+					fields[k] = f
+				}
+				l.Fields = fields
+				layers = append(layers, l)
+				continue
+			}
+			m := &layers[i]
+			if l.MinZoom < m.MinZoom {
+				m.MinZoom = l.MinZoom
+			}
+			if l.MaxZoom > m.MaxZoom {
+				m.MaxZoom = l.MaxZoom
+			}
+			if m.Description == "" {
+				m.Description = l.Description
+			}
+			for k, f := range l.Fields {
+				if _, ok := m.Fields[k]; !ok {
+					m.Fields[k] = f
+				}
+			}
+		}
+	}
+	return layers
+}
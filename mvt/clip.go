@@ -0,0 +1,185 @@
+package mvt
+
+import (
+	"math"
+)
+
+// clipPaths clips paths of a geometry of type t
+// to the square lo, lo - hi, hi.
+func clipPaths(t GeomType, paths [][]Coord, lo, hi int) [][]Coord {
+	var out [][]Coord
+	switch t {
+	case Point:
+		for _, path := range paths {
+			var v []Coord
+			for _, p := range path {
+				if lo <= p.X && p.X <= hi && lo <= p.Y && p.Y <= hi {
+					v = append(v, p)
+				}
+			}
+			if len(v) != 0 {
+				out = append(out, v)
+			}
+		}
+	case LineString:
+		for _, path := range paths {
+			out = append(out, clipLine(path, lo, hi)...)
+		}
+	case Polygon:
+		// rings with the orientation of the first ring are
+		// exterior rings, followed by their holes
+		var outer, keep bool
+		for i, ring := range paths {
+			a := area(ring)
+			if a == 0 {
+				continue
+			}
+			if i == 0 || (a > 0) == outer {
+				outer = a > 0
+				c := clipRing(ring, lo, hi)
+				keep = len(c) >= 3 && area(c) != 0
+				if keep {
+					out = append(out, c)
+				}
+			} else if keep {
+				if c := clipRing(ring, lo, hi); len(c) >= 3 && area(c) != 0 {
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func round(v float64) int {
+	return int(math.Floor(v + 0.5))
+}
+
+// clipLine returns the parts of path within the square lo, lo - hi, hi.
+func clipLine(path []Coord, lo, hi int) [][]Coord {
+	var out [][]Coord
+	var cur []Coord
+	flush := func() {
+		if len(cur) >= 2 {
+			out = append(out, cur)
+		}
+		cur = nil
+	}
+	for i := 0; i+1 < len(path); i++ {
+		p, q, ok := clipSegment(path[i], path[i+1], lo, hi)
+		if !ok {
+			flush()
+			continue
+		}
+		if len(cur) == 0 || cur[len(cur)-1] != p {
+			flush()
+			cur = append(cur, p)
+		}
+		if q != p {
+			cur = append(cur, q)
+		}
+		if q != path[i+1] {
+			// segment leaves the square
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// clipSegment clips the segment p-q to the square
+// lo, lo - hi, hi using the Liang-Barsky algorithm.
+func clipSegment(p, q Coord, lo, hi int) (Coord, Coord, bool) {
+	dx, dy := float64(q.X-p.X), float64(q.Y-p.Y)
+	t0, t1 := 0.0, 1.0
+	for _, c := range [4][2]float64{
+		{-dx, float64(p.X - lo)},
+		{dx, float64(hi - p.X)},
+		{-dy, float64(p.Y - lo)},
+		{dy, float64(hi - p.Y)},
+	} {
+		if c[0] == 0 {
+			if c[1] < 0 {
+				return p, q, false
+			}
+			continue
+		}
+		r := c[1] / c[0]
+		if c[0] < 0 {
+			t0 = math.Max(t0, r)
+		} else {
+			t1 = math.Min(t1, r)
+		}
+	}
+	if t0 > t1 {
+		return p, q, false
+	}
+	at := func(t float64) Coord {
+		return Coord{p.X + round(t*dx), p.Y + round(t*dy)}
+	}
+	a, b := p, q
+	if t0 > 0 {
+		a = at(t0)
+	}
+	if t1 < 1 {
+		b = at(t1)
+	}
+	return a, b, true
+}
+
+// clipRing clips the polygon ring to the square lo, lo - hi, hi
+// using the Sutherland-Hodgman algorithm.
+func clipRing(ring []Coord, lo, hi int) []Coord {
+	type edge struct {
+		inside func(p Coord) bool
+		cross  func(p, q Coord) Coord
+	}
+	atX := func(x int) func(p, q Coord) Coord {
+		return func(p, q Coord) Coord {
+			t := float64(x-p.X) / float64(q.X-p.X)
+			return Coord{x, p.Y + round(t*float64(q.Y-p.Y))}
+		}
+	}
+	atY := func(y int) func(p, q Coord) Coord {
+		return func(p, q Coord) Coord {
+			t := float64(y-p.Y) / float64(q.Y-p.Y)
+			return Coord{p.X + round(t*float64(q.X-p.X)), y}
+		}
+	}
+	edges := []edge{
+		{func(p Coord) bool { return p.X >= lo }, atX(lo)},
+		{func(p Coord) bool { return p.X <= hi }, atX(hi)},
+		{func(p Coord) bool { return p.Y >= lo }, atY(lo)},
+		{func(p Coord) bool { return p.Y <= hi }, atY(hi)},
+	}
+	v := ring
+	for _, e := range edges {
+		if len(v) == 0 {
+			break
+		}
+		in := v
+		v = nil
+		add := func(p Coord) {
+			if len(v) == 0 || v[len(v)-1] != p {
+				v = append(v, p)
+			}
+		}
+		prev := in[len(in)-1]
+		for _, p := range in {
+			switch {
+			case e.inside(p):
+				if !e.inside(prev) {
+					add(e.cross(prev, p))
+				}
+				add(p)
+			case e.inside(prev):
+				add(e.cross(prev, p))
+			}
+			prev = p
+		}
+		if len(v) > 1 && v[0] == v[len(v)-1] {
+			v = v[:len(v)-1]
+		}
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+package mvt
+
+// Concat returns the uncompressed vector tile with the layers of the
+// uncompressed tiles, see Append.
+func Concat(tiles ...[]byte) ([]byte, error) {
+	t := new(Tile)
+	for _, data := range tiles {
+		u, err := Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		if err = t.Append(u); err != nil {
+			return nil, err
+		}
+	}
+	return t.Encode(), nil
+}
+
+// Append adds the layers of u to t. The features of layers with a name
+// already in t are added to the existing layer, their geometries scaled
+// to its extent.
+func (t *Tile) Append(u *Tile) error {
+	for _, m := range u.Layers {
+		var l *Layer
+		for _, tl := range t.Layers {
+			if tl.Name == m.Name {
+				l = tl
+				break
+			}
+		}
+		if l == nil {
+			t.Layers = append(t.Layers, m)
+			continue
+		}
+		if err := l.appendFeatures(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFeatures adds the features of m to l, merging their keys and values.
+func (l *Layer) appendFeatures(m *Layer) error {
+	keys := make(map[string]uint32)
+	for i, k := range l.Keys {
+		keys[k] = uint32(i)
+	}
+	values := make(map[string]uint32)
+	for i, v := range l.Values {
+		values[string(v)] = uint32(i)
+	}
+	for _, f := range m.Features {
+		if len(f.Tags)%2 != 0 {
+			return errFormat
+		}
+		g := *f
+		g.Tags = make([]uint32, len(f.Tags))
+		for i := 0; i < len(f.Tags); i += 2 {
+			k, v := f.Tags[i], f.Tags[i+1]
+			if int(k) >= len(m.Keys) || int(v) >= len(m.Values) {
+				return errFormat
+			}
+			ki, ok := keys[m.Keys[k]]
+			if !ok {
+				ki = uint32(len(l.Keys))
+				keys[m.Keys[k]] = ki
+				l.Keys = append(l.Keys, m.Keys[k])
+			}
+			vi, ok := values[string(m.Values[v])]
+			if !ok {
+				vi = uint32(len(l.Values))
+				values[string(m.Values[v])] = vi
+				l.Values = append(l.Values, m.Values[v])
+			}
+			g.Tags[i], g.Tags[i+1] = ki, vi
+		}
+		if m.Extent != l.Extent {
+			paths, err := f.Paths()
+			if err != nil {
+				return err
+			}
+			s := float64(l.Extent) / float64(m.Extent)
+			for _, p := range paths {
+				for i := range p {
+					p[i] = Coord{round(float64(p[i].X) * s), round(float64(p[i].Y) * s)}
+				}
+			}
+			g.SetPaths(paths)
+		}
+		l.Features = append(l.Features, &g)
+	}
+	return nil
+}
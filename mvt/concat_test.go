@@ -0,0 +1,73 @@
+package mvt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	a := &Tile{Layers: []*Layer{{
+		Version:  2,
+		Name:     "roads",
+		Keys:     []string{"class"},
+		Values:   [][]byte{stringValue("primary")},
+		Extent:   4096,
+		Features: []*Feature{{Tags: []uint32{0, 0}, Type: LineString, Geometry: []uint32{9, 4, 4, 18, 0, 16, 16, 0}}},
+	}}}
+	b := &Tile{Layers: []*Layer{
+		{
+			Version:  2,
+			Name:     "roads",
+			Keys:     []string{"name", "class"},
+			Values:   [][]byte{stringValue("main"), stringValue("primary")},
+			Extent:   512,
+			Features: []*Feature{feature(LineString, []Coord{{1, 2}, {3, 4}})},
+		},
+		{
+			Version:  2,
+			Name:     "water",
+			Extent:   4096,
+			Features: []*Feature{feature(Point, []Coord{{5, 5}})},
+		},
+	}}
+	b.Layers[0].Features[0].Tags = []uint32{0, 0, 1, 1}
+
+	data, err := Concat(a.Encode(), b.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Layers) != 2 || got.Layers[0].Name != "roads" || got.Layers[1].Name != "water" {
+		t.Fatalf("layers: got %+v", got.Layers)
+	}
+	roads := got.Layers[0]
+	if want := []string{"class", "name"}; !reflect.DeepEqual(roads.Keys, want) {
+		t.Errorf("keys: got %q, want %q", roads.Keys, want)
+	}
+	if want := [][]byte{stringValue("primary"), stringValue("main")}; !reflect.DeepEqual(roads.Values, want) {
+		t.Errorf("values: got %q, want %q", roads.Values, want)
+	}
+	if len(roads.Features) != 2 {
+		t.Fatalf("got %d road features, want 2", len(roads.Features))
+	}
+	f := roads.Features[1]
+	if want := []uint32{1, 1, 0, 0}; !reflect.DeepEqual(f.Tags, want) {
+		t.Errorf("tags: got %v, want %v", f.Tags, want)
+	}
+	paths, err := f.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]Coord{{{8, 16}, {24, 32}}}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("scaled paths: got %v, want %v", paths, want)
+	}
+
+	bad := &Tile{Layers: []*Layer{{Name: "roads", Extent: 4096,
+		Features: []*Feature{{Tags: []uint32{0, 5}, Type: Point}}}}}
+	if _, err := Concat(a.Encode(), bad.Encode()); err != errFormat {
+		t.Errorf("invalid tags: got %v, want %v", err, errFormat)
+	}
+}
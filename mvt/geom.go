@@ -0,0 +1,100 @@
+package mvt
+
+// geometry commands
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// Coord is a point in tile coordinates, counted
+// from the top left corner of the tile.
+type Coord struct {
+	X, Y int
+}
+
+func zigzag(v int) uint32 {
+	return uint32((int32(v) << 1) ^ (int32(v) >> 31))
+}
+
+func unzigzag(v uint32) int {
+	return int(int32(v>>1) ^ -int32(v&1))
+}
+
+// Paths decodes the geometry of f. Points are returned as a single path,
+// lines and polygon rings as separate paths. Rings are not closed,
+// their last point is not a repetition of the first.
+func (f *Feature) Paths() ([][]Coord, error) {
+	var paths [][]Coord
+	var x, y int
+	g := f.Geometry
+	for i := 0; i < len(g); {
+		cmd, n := g[i]&7, int(g[i]>>3)
+		i++
+		switch cmd {
+		case cmdMoveTo, cmdLineTo:
+			if n > (len(g)-i)/2 || (cmd == cmdLineTo && len(paths) == 0) {
+				return nil, errFormat
+			}
+			for j := 0; j < n; j++ {
+				x += unzigzag(g[i])
+				y += unzigzag(g[i+1])
+				i += 2
+				if cmd == cmdMoveTo && (f.Type != Point || len(paths) == 0) {
+					paths = append(paths, nil)
+				}
+				k := len(paths) - 1
+				paths[k] = append(paths[k], Coord{x, y})
+			}
+		case cmdClosePath:
+		default:
+			return nil, errFormat
+		}
+	}
+	return paths, nil
+}
+
+// SetPaths sets the geometry of f to paths
+// in the format returned by Paths.
+func (f *Feature) SetPaths(paths [][]Coord) {
+	var g []uint32
+	var x, y int
+	add := func(p Coord) {
+		g = append(g, zigzag(p.X-x), zigzag(p.Y-y))
+		x, y = p.X, p.Y
+	}
+	for _, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		if f.Type == Point {
+			g = append(g, uint32(len(path))<<3|cmdMoveTo)
+			for _, p := range path {
+				add(p)
+			}
+			continue
+		}
+		g = append(g, 1<<3|cmdMoveTo)
+		add(path[0])
+		if len(path) > 1 {
+			g = append(g, uint32(len(path)-1)<<3|cmdLineTo)
+			for _, p := range path[1:] {
+				add(p)
+			}
+		}
+		if f.Type == Polygon {
+			g = append(g, 1<<3|cmdClosePath)
+		}
+	}
+	f.Geometry = g
+}
+
+// area returns twice the signed area of ring.
+func area(ring []Coord) int {
+	var a int
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a
+}
@@ -0,0 +1,193 @@
+// Package mvt reads and writes Mapbox Vector Tiles.
+//
+// Only the structure needed to transform tiles is decoded,
+// feature attribute values are kept in their encoded form.
+package mvt
+
+// GeomType is the geometry type of a feature.
+type GeomType int
+
+const (
+	Unknown GeomType = iota
+	Point
+	LineString
+	Polygon
+)
+
+// DefaultExtent is the extent of layers not specifying one.
+const DefaultExtent = 4096
+
+// Tile is a vector tile.
+type Tile struct {
+	Layers []*Layer
+}
+
+// Layer is a named layer of a tile.
+type Layer struct {
+	Version  int
+	Name     string
+	Features []*Feature
+	Keys     []string
+	Values   [][]byte // encoded Value messages
+	Extent   int
+}
+
+// Feature is a feature of a layer. Tags are pairs of indexes into the
+// Keys and Values of the layer. Geometry holds the encoded geometry
+// commands, use Paths and SetPaths to access the coordinates.
+type Feature struct {
+	ID       uint64
+	HasID    bool
+	Tags     []uint32
+	Type     GeomType
+	Geometry []uint32
+}
+
+// field numbers
+const (
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+)
+
+// Decode decodes the uncompressed vector tile data.
+// Unknown fields are ignored.
+func Decode(data []byte) (*Tile, error) {
+	t := new(Tile)
+	m := message{b: data}
+	for {
+		f, w, ok := m.next()
+		if !ok {
+			break
+		}
+		if f != tileLayers || w != wireBytes {
+			m.skip(w)
+			continue
+		}
+		l, err := decodeLayer(m.bytes())
+		if err != nil {
+			return nil, err
+		}
+		t.Layers = append(t.Layers, l)
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return t, nil
+}
+
+func decodeLayer(data []byte) (*Layer, error) {
+	l := &Layer{Version: 1, Extent: DefaultExtent}
+	m := message{b: data}
+	for {
+		f, w, ok := m.next()
+		if !ok {
+			break
+		}
+		switch {
+		case f == layerVersion && w == wireVarint:
+			l.Version = int(m.varint())
+		case f == layerName && w == wireBytes:
+			l.Name = string(m.bytes())
+		case f == layerFeatures && w == wireBytes:
+			ft, err := decodeFeature(m.bytes())
+			if err != nil {
+				return nil, err
+			}
+			l.Features = append(l.Features, ft)
+		case f == layerKeys && w == wireBytes:
+			l.Keys = append(l.Keys, string(m.bytes()))
+		case f == layerValues && w == wireBytes:
+			l.Values = append(l.Values, m.bytes())
+		case f == layerExtent && w == wireVarint:
+			l.Extent = int(m.varint())
+		default:
+			m.skip(w)
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	if l.Extent <= 0 {
+		return nil, errFormat
+	}
+	return l, nil
+}
+
+func decodeFeature(data []byte) (*Feature, error) {
+	ft := new(Feature)
+	m := message{b: data}
+	for {
+		f, w, ok := m.next()
+		if !ok {
+			break
+		}
+		switch {
+		case f == featureID && w == wireVarint:
+			ft.ID, ft.HasID = m.varint(), true
+		case f == featureTags && w == wireBytes:
+			ft.Tags = m.packed()
+		case f == featureType && w == wireVarint:
+			ft.Type = GeomType(m.varint())
+		case f == featureGeometry && w == wireBytes:
+			ft.Geometry = m.packed()
+		default:
+			m.skip(w)
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return ft, nil
+}
+
+// Encode returns the uncompressed encoding of t.
+func (t *Tile) Encode() []byte {
+	var b []byte
+	for _, l := range t.Layers {
+		b = appendBytes(b, tileLayers, l.encode())
+	}
+	return b
+}
+
+func (l *Layer) encode() []byte {
+	var b []byte
+	b = appendKey(b, layerVersion, wireVarint)
+	b = appendVarint(b, uint64(l.Version))
+	b = appendBytes(b, layerName, []byte(l.Name))
+	for _, f := range l.Features {
+		b = appendBytes(b, layerFeatures, f.encode())
+	}
+	for _, k := range l.Keys {
+		b = appendBytes(b, layerKeys, []byte(k))
+	}
+	for _, v := range l.Values {
+		b = appendBytes(b, layerValues, v)
+	}
+	b = appendKey(b, layerExtent, wireVarint)
+	return appendVarint(b, uint64(l.Extent))
+}
+
+func (f *Feature) encode() []byte {
+	var b []byte
+	if f.HasID {
+		b = appendKey(b, featureID, wireVarint)
+		b = appendVarint(b, f.ID)
+	}
+	if len(f.Tags) != 0 {
+		b = appendPacked(b, featureTags, f.Tags)
+	}
+	b = appendKey(b, featureType, wireVarint)
+	b = appendVarint(b, uint64(f.Type))
+	return appendPacked(b, featureGeometry, f.Geometry)
+}
@@ -0,0 +1,136 @@
+package mvt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// stringValue returns the encoded Value message of s.
+func stringValue(s string) []byte {
+	return appendBytes(nil, 1, []byte(s))
+}
+
+// testTile returns a tile with a layer of each geometry type.
+func testTile() *Tile {
+	return &Tile{Layers: []*Layer{
+		{
+			Version: 2,
+			Name:    "pois",
+			Keys:    []string{"name", "kind"},
+			Values:  [][]byte{stringValue("well"), stringValue("water")},
+			Extent:  4096,
+			Features: []*Feature{
+				{ID: 1, HasID: true, Tags: []uint32{0, 0, 1, 1}, Type: Point, Geometry: []uint32{9, 50, 34}},
+				{Type: Point, Geometry: []uint32{17, 10, 14, 3, 9}},
+			},
+		},
+		{
+			Version: 2,
+			Name:    "roads",
+			Extent:  512,
+			Features: []*Feature{
+				{ID: 2, HasID: true, Type: LineString, Geometry: []uint32{9, 4, 4, 18, 0, 16, 16, 0}},
+			},
+		},
+		{
+			Version: 2,
+			Name:    "areas",
+			Extent:  4096,
+			Features: []*Feature{
+				{Type: Polygon, Geometry: []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15}},
+			},
+		},
+	}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := testTile()
+	data := want.Encode()
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\ngot  %+v\nwant %+v", got, want)
+	}
+	if again := got.Encode(); !bytes.Equal(again, data) {
+		t.Errorf("encoding changed:\ngot  %x\nwant %x", again, data)
+	}
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	// layer with a name only, and an unknown field
+	l := appendBytes(nil, layerName, []byte("bare"))
+	l = appendBytes(l, 99, []byte("ignored"))
+	got, err := Decode(appendBytes(nil, tileLayers, l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Tile{Layers: []*Layer{{Version: 1, Name: "bare", Extent: DefaultExtent}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	data := testTile().Encode()
+	for _, n := range []int{1, 2, 10, len(data) / 2, len(data) - 1} {
+		if _, err := Decode(data[:n]); err != errFormat {
+			t.Errorf("truncated to %d bytes: got %v, want %v", n, err, errFormat)
+		}
+	}
+	l := appendKey(nil, layerExtent, wireVarint)
+	l = appendVarint(l, 0)
+	if _, err := Decode(appendBytes(nil, tileLayers, l)); err != errFormat {
+		t.Errorf("zero extent: got %v, want %v", err, errFormat)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	// examples of the vector tile specification
+	tests := []struct {
+		typ   GeomType
+		geom  []uint32
+		paths [][]Coord
+	}{
+		{Point, []uint32{9, 50, 34}, [][]Coord{{{25, 17}}}},
+		{Point, []uint32{17, 10, 14, 3, 9}, [][]Coord{{{5, 7}, {3, 2}}}},
+		{LineString, []uint32{9, 4, 4, 18, 0, 16, 16, 0},
+			[][]Coord{{{2, 2}, {2, 10}, {10, 10}}}},
+		{LineString, []uint32{9, 4, 4, 18, 0, 16, 16, 0, 9, 17, 17, 10, 4, 8},
+			[][]Coord{{{2, 2}, {2, 10}, {10, 10}}, {{1, 1}, {3, 5}}}},
+		{Polygon, []uint32{9, 6, 12, 18, 10, 12, 24, 44, 15},
+			[][]Coord{{{3, 6}, {8, 12}, {20, 34}}}},
+		{Polygon, []uint32{9, 0, 0, 26, 20, 0, 0, 20, 19, 0, 15, 9, 22, 2, 26, 18, 0, 0, 18, 17, 0, 15},
+			[][]Coord{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, {{11, 11}, {20, 11}, {20, 20}, {11, 20}}}},
+	}
+	for _, tt := range tests {
+		f := &Feature{Type: tt.typ, Geometry: tt.geom}
+		paths, err := f.Paths()
+		if err != nil {
+			t.Errorf("%v: %v", tt.geom, err)
+			continue
+		}
+		if !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("Paths of %v = %v, want %v", tt.geom, paths, tt.paths)
+		}
+		g := &Feature{Type: tt.typ}
+		g.SetPaths(tt.paths)
+		if !reflect.DeepEqual(g.Geometry, tt.geom) {
+			t.Errorf("SetPaths(%v) = %v, want %v", tt.paths, g.Geometry, tt.geom)
+		}
+	}
+
+	for _, geom := range [][]uint32{
+		{9, 50},      // missing coordinate
+		{18, 0, 16},  // LineTo without MoveTo
+		{9, 4, 4, 3}, // unknown command
+		{17, 10, 14}, // too few points
+	} {
+		f := &Feature{Type: LineString, Geometry: geom}
+		if _, err := f.Paths(); err != errFormat {
+			t.Errorf("Paths of %v: got %v, want %v", geom, err, errFormat)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package mvt
+
+import (
+	"errors"
+)
+
+// BufferDiv specifies the buffer kept around overzoomed tiles
+// as a fraction of the layer extent.
+const BufferDiv = 16
+
+// Overzoom returns the uncompressed vector tile covering the part
+// of the uncompressed tile data dz zoom levels deeper at column x
+// and row y relative to the tile, counted from the top left.
+func Overzoom(data []byte, dz, x, y int) ([]byte, error) {
+	t, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.Overzoom(dz, x, y); err != nil {
+		return nil, err
+	}
+	return t.Encode(), nil
+}
+
+// Overzoom scales up the geometries of t to cover its descendant dz zoom
+// levels deeper at column x and row y relative to t, counted from the top
+// left. Geometries are clipped to the new tile extended with a buffer
+// of 1/BufferDiv of the extent. Features and layers left without
+// geometry are removed.
+func (t *Tile) Overzoom(dz, x, y int) error {
+	n := 1 << uint(dz)
+	if dz < 0 || dz > 24 || x < 0 || y < 0 || x >= n || y >= n {
+		return errors.New("mvt: invalid overzoom tile")
+	}
+	layers := t.Layers[:0]
+	for _, l := range t.Layers {
+		ext := l.Extent
+		buf := ext / BufferDiv
+		features := l.Features[:0]
+		for _, f := range l.Features {
+			paths, err := f.Paths()
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				for i, p := range path {
+					path[i] = Coord{p.X*n - x*ext, p.Y*n - y*ext}
+				}
+			}
+			paths = clipPaths(f.Type, paths, -buf, ext+buf)
+			if len(paths) == 0 {
+				continue
+			}
+			f.SetPaths(paths)
+			features = append(features, f)
+		}
+		l.Features = features
+		if len(features) != 0 {
+			layers = append(layers, l)
+		}
+	}
+	t.Layers = layers
+	return nil
+}
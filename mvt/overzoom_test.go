@@ -0,0 +1,146 @@
+package mvt
+
+import (
+	"reflect"
+	"testing"
+)
+
+// feature returns a feature of type t with paths.
+func feature(t GeomType, paths ...[]Coord) *Feature {
+	f := &Feature{Type: t}
+	f.SetPaths(paths)
+	return f
+}
+
+func TestOverzoom(t *testing.T) {
+	// top right quarter of a tile, with a buffer of 256 units
+	src := &Tile{Layers: []*Layer{
+		{
+			Version: 2,
+			Name:    "pois",
+			Extent:  4096,
+			Features: []*Feature{
+				feature(Point, []Coord{{3000, 500}, {100, 100}}),
+				feature(Point, []Coord{{100, 3000}}), // outside
+			},
+		},
+		{
+			Version: 2,
+			Name:    "roads",
+			Extent:  4096,
+			Features: []*Feature{
+				feature(LineString, []Coord{{0, 1024}, {4096, 1024}}),
+				// leaves and enters the tile
+				feature(LineString, []Coord{{3072, 0}, {3072, 3072}, {1024, 3072}, {1024, 1536}, {3072, 1536}}),
+			},
+		},
+		{
+			Version: 2,
+			Name:    "areas",
+			Extent:  4096,
+			Features: []*Feature{
+				// covers the tile
+				feature(Polygon, []Coord{{1024, 0}, {4096, 0}, {4096, 2048}, {1024, 2048}}),
+				// clipped at the bottom left, with a hole outside the tile
+				feature(Polygon,
+					[]Coord{{1024, 1024}, {3072, 1024}, {3072, 3072}, {1024, 3072}},
+					[]Coord{{1200, 2800}, {1200, 2900}, {1300, 2900}, {1300, 2800}}),
+			},
+		},
+		{
+			Version: 2,
+			Name:    "lakes",
+			Extent:  4096,
+			Features: []*Feature{
+				feature(Polygon, []Coord{{0, 3000}, {1000, 3000}, {1000, 4000}, {0, 4000}}),
+			},
+		},
+	}}
+	data := src.Encode()
+	out, err := Overzoom(data, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][][][]Coord{
+		"pois": {
+			{{{1904, 1000}}},
+		},
+		"roads": {
+			{{{-256, 2048}, {4096, 2048}}},
+			{{{2048, 0}, {2048, 4352}}, {{-256, 3072}, {2048, 3072}}},
+		},
+		"areas": {
+			{{{-256, 0}, {4096, 0}, {4096, 4096}, {-256, 4096}}},
+			{{{-256, 4352}, {-256, 2048}, {2048, 2048}, {2048, 4352}}},
+		},
+	}
+	if len(got.Layers) != len(want) {
+		t.Errorf("got %d layers, want %d", len(got.Layers), len(want))
+	}
+	for _, l := range got.Layers {
+		w, ok := want[l.Name]
+		if !ok {
+			t.Errorf("unexpected layer %q", l.Name)
+			continue
+		}
+		var paths [][][]Coord
+		for _, f := range l.Features {
+			p, err := f.Paths()
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, p)
+		}
+		if !reflect.DeepEqual(paths, w) {
+			t.Errorf("layer %s:\ngot  %v\nwant %v", l.Name, paths, w)
+		}
+	}
+
+	// the top left tile at zoom 0 is the tile itself
+	same, err := Overzoom(data, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = Decode(same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, src) {
+		t.Errorf("overzoom by 0 levels changed the tile")
+	}
+}
+
+func TestOverzoomError(t *testing.T) {
+	data := (&Tile{}).Encode()
+	for _, c := range [][3]int{{-1, 0, 0}, {25, 0, 0}, {1, 2, 0}, {1, 0, 2}, {2, -1, 0}} {
+		if _, err := Overzoom(data, c[0], c[1], c[2]); err == nil {
+			t.Errorf("Overzoom(%d, %d, %d) succeeded", c[0], c[1], c[2])
+		}
+	}
+	if _, err := Overzoom([]byte{0x1a, 0x10}, 1, 0, 0); err != errFormat {
+		t.Errorf("malformed tile: got %v, want %v", err, errFormat)
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	tests := []struct {
+		path []Coord
+		want [][]Coord
+	}{
+		{[]Coord{{1, 1}, {9, 9}}, [][]Coord{{{1, 1}, {9, 9}}}},
+		{[]Coord{{-10, 5}, {20, 5}}, [][]Coord{{{0, 5}, {10, 5}}}},
+		{[]Coord{{-10, -10}, {-5, 20}}, nil},
+		{[]Coord{{5, 5}, {5, 20}, {8, 20}, {8, 5}}, [][]Coord{{{5, 5}, {5, 10}}, {{8, 10}, {8, 5}}}},
+		{[]Coord{{-5, 5}, {5, 15}}, nil}, // touches a corner
+	}
+	for _, tt := range tests {
+		if got := clipLine(tt.path, 0, 10); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("clipLine(%v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
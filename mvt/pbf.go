@@ -0,0 +1,131 @@
+package mvt
+
+// minimal protocol buffers encoding
+
+import (
+	"errors"
+)
+
+var errFormat = errors.New("mvt: malformed tile")
+
+// wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// message reads the fields of a protocol buffers message.
+type message struct {
+	b   []byte
+	err error
+}
+
+// next reads the key of the next field. It returns
+// false at the end of the message or on errors.
+func (m *message) next() (field int, wire int, ok bool) {
+	if m.err != nil || len(m.b) == 0 {
+		return 0, 0, false
+	}
+	k := m.varint()
+	if m.err != nil {
+		return 0, 0, false
+	}
+	return int(k >> 3), int(k & 7), true
+}
+
+func (m *message) varint() uint64 {
+	var v uint64
+	for i := uint(0); i < 64; i += 7 {
+		if len(m.b) == 0 {
+			break
+		}
+		c := m.b[0]
+		m.b = m.b[1:]
+		v |= uint64(c&0x7f) << i
+		if c < 0x80 {
+			return v
+		}
+	}
+	m.err = errFormat
+	return 0
+}
+
+func (m *message) bytes() []byte {
+	n := m.varint()
+	if m.err != nil {
+		return nil
+	}
+	if n > uint64(len(m.b)) {
+		m.err = errFormat
+		return nil
+	}
+	v := m.b[:n]
+	m.b = m.b[n:]
+	return v
+}
+
+// packed reads a packed repeated varint field.
+func (m *message) packed() []uint32 {
+	p := message{b: m.bytes()}
+	var v []uint32
+	for len(p.b) != 0 && p.err == nil {
+		v = append(v, uint32(p.varint()))
+	}
+	if p.err != nil {
+		m.err = p.err
+	}
+	return v
+}
+
+// skip skips the value of a field with wire type wire.
+func (m *message) skip(wire int) {
+	var n int
+	switch wire {
+	case wireVarint:
+		m.varint()
+		return
+	case wireBytes:
+		m.bytes()
+		return
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		m.err = errFormat
+		return
+	}
+	if n > len(m.b) {
+		m.err = errFormat
+		return
+	}
+	m.b = m.b[n:]
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendKey(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendKey(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendPacked(b []byte, field int, v []uint32) []byte {
+	var p []byte
+	for _, x := range v {
+		p = appendVarint(p, uint64(x))
+	}
+	return appendBytes(b, field, p)
+}
@@ -0,0 +1,54 @@
+package pmtiles
+
+import (
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"os"
+)
+
+// FromMBTiles creates the PMTiles archive fn from the tiles
+// and metadata of mbt. UTFGrids are not converted.
+func FromMBTiles(mbt *mbtiles.Map, fn string) error {
+	w, err := Create(fn)
+	if err != nil {
+		return err
+	}
+	it := mbt.Tiles(nil)
+	for it.Next() {
+		if err = w.PutTile(it.Tile()); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = it.Err()
+	}
+	it.Close()
+	if err == nil {
+		err = w.WriteMetadata(mbt.Metadata())
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	} else {
+		os.Remove(fn)
+	}
+	return err
+}
+
+// ToMBTiles creates the MBTiles file fn with the given schema
+// from the tiles and metadata of r.
+func ToMBTiles(r *Reader, fn string, schema mbtiles.Schema) error {
+	w, err := mbtiles.CreateSchema(fn, schema)
+	if err != nil {
+		return err
+	}
+	err = r.Walk(w.PutTile)
+	if err == nil {
+		err = w.WriteMetadata(r.Metadata())
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fn)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package pmtiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// entry is a directory entry. Entries with zero RunLength
+// point to leaf directories, the rest to tile data.
+type entry struct {
+	TileID    uint64
+	Offset    uint64
+	Length    uint32
+	RunLength uint32
+}
+
+var errDirectory = errors.New("pmtiles: malformed directory")
+
+func serializeDirectory(entries []entry) []byte {
+	var buf []byte
+	tmp := make([]byte, binary.MaxVarintLen64)
+	put := func(v uint64) {
+		n := binary.PutUvarint(tmp, v)
+		buf = append(buf, tmp[:n]...)
+	}
+	put(uint64(len(entries)))
+	var last uint64
+	for _, e := range entries {
+		put(e.TileID - last)
+		last = e.TileID
+	}
+	for _, e := range entries {
+		put(uint64(e.RunLength))
+	}
+	for _, e := range entries {
+		put(uint64(e.Length))
+	}
+	for i, e := range entries {
+		if i > 0 && e.Offset == entries[i-1].Offset+uint64(entries[i-1].Length) {
+			put(0)
+		} else {
+			put(e.Offset + 1)
+		}
+	}
+	return buf
+}
+
+func deserializeDirectory(b []byte) ([]entry, error) {
+	r := bytes.NewReader(b)
+	get := func() (uint64, error) {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, errDirectory
+		}
+		return v, nil
+	}
+	n, err := get()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(b)) {
+		// each entry takes at least 4 bytes
+		return nil, errDirectory
+	}
+	entries := make([]entry, n)
+	var last uint64
+	for i := range entries {
+		v, err := get()
+		if err != nil {
+			return nil, err
+		}
+		last += v
+		entries[i].TileID = last
+	}
+	for i := range entries {
+		v, err := get()
+		if err != nil {
+			return nil, err
+		}
+		entries[i].RunLength = uint32(v)
+	}
+	for i := range entries {
+		v, err := get()
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Length = uint32(v)
+	}
+	for i := range entries {
+		v, err := get()
+		if err != nil {
+			return nil, err
+		}
+		if v == 0 && i > 0 {
+			entries[i].Offset = entries[i-1].Offset + uint64(entries[i-1].Length)
+		} else {
+			entries[i].Offset = v - 1
+		}
+	}
+	return entries, nil
+}
+
+// findTile returns the entry containing id, or the entry of
+// the leaf directory that may contain it.
+func findTile(entries []entry, id uint64) (entry, bool) {
+	m, n := 0, len(entries)-1
+	for m <= n {
+		k := (m + n) / 2
+		switch {
+		case id > entries[k].TileID:
+			m = k + 1
+		case id < entries[k].TileID:
+			n = k - 1
+		default:
+			return entries[k], true
+		}
+	}
+	// entries[n].TileID < id here
+	if n >= 0 {
+		e := entries[n]
+		if e.RunLength == 0 || id-e.TileID < uint64(e.RunLength) {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+func compress(b []byte, c uint8) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return b, nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("pmtiles: unsupported compression %d", c)
+}
+
+func decompress(b []byte, c uint8) ([]byte, error) {
+	switch c {
+	case NoCompression, UnknownCompression:
+		return b, nil
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		b, err = ioutil.ReadAll(io.LimitReader(zr, maxInternalLength+1))
+		if err == nil && len(b) > maxInternalLength {
+			err = errDirectory
+		}
+		return b, err
+	}
+	return nil, fmt.Errorf("pmtiles: unsupported compression %d", c)
+}
@@ -0,0 +1,127 @@
+package pmtiles
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HeaderLen is the length of the PMTiles v3 header.
+const HeaderLen = 127
+
+// rootLimit is the size limit of the header and root directory,
+// clients fetch this amount of data first.
+const rootLimit = 16384
+
+// Compression values in the header
+const (
+	UnknownCompression = 0
+	NoCompression      = 1
+	Gzip               = 2
+	Brotli             = 3
+	Zstd               = 4
+)
+
+// TileType values in the header
+const (
+	UnknownTileType = 0
+	MVT             = 1
+	PNG             = 2
+	JPEG            = 3
+	WebP            = 4
+	AVIF            = 5
+)
+
+// Header is the PMTiles v3 header.
+type Header struct {
+	RootOffset, RootLength         uint64
+	MetadataOffset, MetadataLength uint64
+	LeafOffset, LeafLength         uint64
+	TileDataOffset, TileDataLength uint64
+
+	AddressedTiles uint64 // number of tiles addressed
+	TileEntries    uint64 // number of directory entries pointing to tile data
+	TileContents   uint64 // number of distinct tile contents
+
+	Clustered           bool
+	InternalCompression uint8
+	TileCompression     uint8
+	TileType            uint8
+
+	MinZoom, MaxZoom         uint8
+	MinLonE7, MinLatE7       int32
+	MaxLonE7, MaxLatE7       int32
+	CenterZoom               uint8
+	CenterLonE7, CenterLatE7 int32
+}
+
+var errBadMagic = errors.New("pmtiles: not a PMTiles v3 file")
+
+func parseHeader(b []byte) (*Header, error) {
+	if len(b) < HeaderLen || string(b[:7]) != "PMTiles" {
+		return nil, errBadMagic
+	}
+	if b[7] != 3 {
+		return nil, errors.New("pmtiles: unsupported version")
+	}
+	le := binary.LittleEndian
+	u64 := func(o int) uint64 { return le.Uint64(b[o:]) }
+	i32 := func(o int) int32 { return int32(le.Uint32(b[o:])) }
+	return &Header{
+		RootOffset:          u64(8),
+		RootLength:          u64(16),
+		MetadataOffset:      u64(24),
+		MetadataLength:      u64(32),
+		LeafOffset:          u64(40),
+		LeafLength:          u64(48),
+		TileDataOffset:      u64(56),
+		TileDataLength:      u64(64),
+		AddressedTiles:      u64(72),
+		TileEntries:         u64(80),
+		TileContents:        u64(88),
+		Clustered:           b[96] == 1,
+		InternalCompression: b[97],
+		TileCompression:     b[98],
+		TileType:            b[99],
+		MinZoom:             b[100],
+		MaxZoom:             b[101],
+		MinLonE7:            i32(102),
+		MinLatE7:            i32(106),
+		MaxLonE7:            i32(110),
+		MaxLatE7:            i32(114),
+		CenterZoom:          b[118],
+		CenterLonE7:         i32(119),
+		CenterLatE7:         i32(123),
+	}, nil
+}
+
+func (h *Header) bytes() []byte {
+	b := make([]byte, HeaderLen)
+	copy(b, "PMTiles")
+	b[7] = 3
+	le := binary.LittleEndian
+	for i, v := range []uint64{
+		h.RootOffset, h.RootLength,
+		h.MetadataOffset, h.MetadataLength,
+		h.LeafOffset, h.LeafLength,
+		h.TileDataOffset, h.TileDataLength,
+		h.AddressedTiles, h.TileEntries, h.TileContents,
+	} {
+		le.PutUint64(b[8+8*i:], v)
+	}
+	if h.Clustered {
+		b[96] = 1
+	}
+	b[97] = h.InternalCompression
+	b[98] = h.TileCompression
+	b[99] = h.TileType
+	b[100] = h.MinZoom
+	b[101] = h.MaxZoom
+	le.PutUint32(b[102:], uint32(h.MinLonE7))
+	le.PutUint32(b[106:], uint32(h.MinLatE7))
+	le.PutUint32(b[110:], uint32(h.MaxLonE7))
+	le.PutUint32(b[114:], uint32(h.MaxLatE7))
+	b[118] = h.CenterZoom
+	le.PutUint32(b[119:], uint32(h.CenterLonE7))
+	le.PutUint32(b[123:], uint32(h.CenterLatE7))
+	return b
+}
@@ -0,0 +1,285 @@
+package pmtiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		id      uint64
+	}{
+		{0, 0, 0, 0},
+		{1, 0, 0, 1},
+		{1, 0, 1, 2},
+		{1, 1, 1, 3},
+		{1, 1, 0, 4},
+		{2, 0, 0, 5},
+		{2, 1, 0, 6},
+		{2, 0, 1, 8},
+		{2, 3, 0, 20},
+		{3, 0, 0, 21},
+		{3, 7, 0, 84},
+		{4, 0, 0, 85},
+		{5, 0, 0, 341},
+		{12, 3423, 1763, 19078479},
+		{20, 0, 0, 366503875925},
+	}
+	for _, tt := range tests {
+		if got := TileID(tt.z, tt.x, tt.y); got != tt.id {
+			t.Errorf("TileID(%d, %d, %d) = %d, want %d", tt.z, tt.x, tt.y, got, tt.id)
+		}
+		if z, x, y := TileCoord(tt.id); z != tt.z || x != tt.x || y != tt.y {
+			t.Errorf("TileCoord(%d) = %d, %d, %d, want %d, %d, %d", tt.id, z, x, y, tt.z, tt.x, tt.y)
+		}
+	}
+}
+
+func TestTileIDOrder(t *testing.T) {
+	// ids of a zoom level are consecutive, and neighbours
+	// along the Hilbert curve are adjacent tiles
+	for z := 0; z <= 4; z++ {
+		first := TileID(z, 0, 0)
+		n := uint64(1) << uint(2*z)
+		var px, py int
+		for id := first; id < first+n; id++ {
+			cz, cx, cy := TileCoord(id)
+			if cz != z || TileID(cz, cx, cy) != id {
+				t.Fatalf("TileCoord(%d) = %d, %d, %d", id, cz, cx, cy)
+			}
+			if d := abs(cx-px) + abs(cy-py); id > first && d != 1 {
+				t.Fatalf("tiles %d and %d are not adjacent", id-1, id)
+			}
+			px, py = cx, cy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestDirectory(t *testing.T) {
+	entries := []entry{
+		{TileID: 0, Offset: 0, Length: 100, RunLength: 1},
+		{TileID: 1, Offset: 100, Length: 20, RunLength: 4}, // contiguous
+		{TileID: 5, Offset: 0, Length: 100, RunLength: 1},  // repeated content
+		{TileID: 300, Offset: 1 << 40, Length: 1 << 20, RunLength: 1 << 20},
+		{TileID: 1 << 40, Offset: 7, Length: 1000, RunLength: 0}, // leaf
+	}
+	b := serializeDirectory(entries)
+	got, err := deserializeDirectory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("round trip: got %+v, want %+v", got, entries)
+	}
+
+	if got, err := deserializeDirectory(serializeDirectory(nil)); err != nil || len(got) != 0 {
+		t.Errorf("empty directory: got %v, %v", got, err)
+	}
+	for n := 0; n < len(b); n++ {
+		if _, err := deserializeDirectory(b[:n]); err != errDirectory {
+			t.Errorf("truncated to %d bytes: got %v, want %v", n, err, errDirectory)
+		}
+	}
+}
+
+func TestFindTile(t *testing.T) {
+	entries := []entry{
+		{TileID: 0, Offset: 0, Length: 1, RunLength: 1},
+		{TileID: 5, Offset: 1, Length: 1, RunLength: 3},
+		{TileID: 20, Offset: 0, Length: 1, RunLength: 0}, // leaf
+	}
+	tests := []struct {
+		id    uint64
+		found bool
+		entry int
+	}{
+		{0, true, 0},
+		{1, false, 0},
+		{4, false, 0},
+		{5, true, 1},
+		{7, true, 1},
+		{8, false, 0},
+		{19, false, 0},
+		{20, true, 2},
+		{1000, true, 2},
+	}
+	for _, tt := range tests {
+		e, ok := findTile(entries, tt.id)
+		if ok != tt.found || ok && e != entries[tt.entry] {
+			t.Errorf("findTile(%d) = %+v, %v, want entry %d, %v", tt.id, e, ok, tt.entry, tt.found)
+		}
+	}
+	if _, ok := findTile(nil, 0); ok {
+		t.Error("findTile in empty directory found a tile")
+	}
+}
+
+// TestConvert converts an MBTiles file with enough tiles to need leaf
+// directories to PMTiles and back.
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pmtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.mbtiles")
+	w, err := mbtiles.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles := make(map[[3]int][]byte)
+	put := func(z, x, y int, data []byte) {
+		tiles[[3]int{z, x, y}] = data
+		if err := w.PutTile(z, x, y, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a run of identical tiles
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			put(3, x, y, []byte("same"))
+		}
+	}
+	// scattered tiles of various sizes
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		z, x, y := 12, rnd.Intn(1<<12), rnd.Intn(1<<12)
+		if tiles[[3]int{z, x, y}] != nil {
+			continue
+		}
+		data := make([]byte, 8+rnd.Intn(200))
+		copy(data, fmt.Sprint(i, "/"))
+		put(z, x, y, data)
+	}
+	if err = w.SetMetadata("name", "convert"); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mbt, err := mbtiles.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := filepath.Join(dir, "test.pmtiles")
+	err = FromMBTiles(mbt, pm)
+	mbt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	h := r.Header()
+	if h.LeafLength == 0 {
+		t.Fatal("no leaf directories")
+	}
+	if h.AddressedTiles != uint64(len(tiles)) || h.TileContents != uint64(len(tiles)-63) {
+		t.Errorf("header: %d tiles addressed, %d contents, want %d, %d",
+			h.AddressedTiles, h.TileContents, len(tiles), len(tiles)-63)
+	}
+	if r.Metadata().Name != "convert" {
+		t.Errorf("metadata name: got %q", r.Metadata().Name)
+	}
+	for k, want := range tiles {
+		if got, err := r.GetTile(k[0], k[1], k[2]); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("tile %v: got %q, %v, want %q", k, got, err, want)
+		}
+	}
+	for _, k := range [][3]int{{11, 5, 5}, {13, 0, 0}, {2, 1, 1}} {
+		if _, err := r.GetTile(k[0], k[1], k[2]); err != mbtiles.ErrTileNotFound {
+			t.Errorf("missing tile %v: got %v, want %v", k, err, mbtiles.ErrTileNotFound)
+		}
+	}
+
+	dst := filepath.Join(dir, "dst.mbtiles")
+	if err = ToMBTiles(r, dst, mbtiles.FlatSchema); err != nil {
+		t.Fatal(err)
+	}
+	mbt, err = mbtiles.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mbt.Close()
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		z, x, y, data := it.Tile()
+		if want := tiles[[3]int{z, x, y}]; !bytes.Equal(data, want) {
+			t.Fatalf("converted tile %d/%d/%d: got %q, want %q", z, x, y, data, want)
+		}
+		n++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(tiles) {
+		t.Errorf("converted file has %d tiles, want %d", n, len(tiles))
+	}
+}
+
+func TestTileCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pmtiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.pmtiles")
+	w, err := Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.PutTile(0, 0, 0, []byte("\x1f\x8bgzip")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := r.Header().TileCompression; c != Gzip {
+		t.Errorf("tile compression: got %d, want %d", c, Gzip)
+	}
+	r.Close()
+
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []uint8{Brotli, Zstd} {
+		b[98] = c
+		if err = ioutil.WriteFile(fn, b, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if r, err := Open(fn); !errors.Is(err, ErrCompression) {
+			if err == nil {
+				r.Close()
+			}
+			t.Errorf("compression %d: got %v, want %v", c, err, ErrCompression)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package pmtiles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxDepth is the maximum number of directory levels followed.
+const maxDepth = 4
+
+// maxLeafCache is the number of leaf directories kept in memory.
+const maxLeafCache = 64
+
+// maxInternalLength is the maximum length of directories
+// and metadata, both compressed and uncompressed.
+const maxInternalLength = 1 << 26
+
+// ErrCompression is returned by Open for archives with tiles
+// compressed other than with gzip, such as Brotli or Zstd.
+var ErrCompression = errors.New("pmtiles: unsupported tile compression")
+
+// errTruncated is returned for data referenced beyond the end of the file.
+var errTruncated = fmt.Errorf("pmtiles: %w: data beyond end of file", mbtiles.ErrCorrupt)
+
+// Reader reads tiles from a PMTiles v3 archive. Its methods
+// mirror those of mbtiles.Map, tile rows are in TMS order.
+type Reader struct {
+	Filename string
+	Mtime    time.Time
+	Size     int64
+
+	fmtx     sync.RWMutex   // guards f
+	f        *os.File       // nil if closed
+	wg       sync.WaitGroup // reads in progress
+	header   *Header
+	root     []entry
+	metadata *mbtiles.Metadata
+
+	mtx    sync.Mutex
+	leaves map[uint64][]entry
+}
+
+var _ mbtiles.ContextTileSource = (*Reader)(nil)
+
+// Open opens the PMTiles archive fn.
+func Open(fn string) (*Reader, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{Filename: fn, f: f, leaves: make(map[uint64][]entry)}
+	if err = r.init(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) init() error {
+	fi, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+	r.Mtime, r.Size = fi.ModTime(), fi.Size()
+
+	b := make([]byte, HeaderLen)
+	if _, err = r.f.ReadAt(b, 0); err != nil {
+		if err == io.EOF {
+			return errBadMagic
+		}
+		return err
+	}
+	if r.header, err = parseHeader(b); err != nil {
+		return err
+	}
+	h := r.header
+	switch h.TileCompression {
+	case UnknownCompression, NoCompression, Gzip:
+	default:
+		return fmt.Errorf("%w %d", ErrCompression, h.TileCompression)
+	}
+	if r.root, err = r.readDirectory(r.f, h.RootOffset, h.RootLength); err != nil {
+		return err
+	}
+	if h.MetadataLength > maxInternalLength {
+		return fmt.Errorf("pmtiles: %w: metadata too large", mbtiles.ErrCorrupt)
+	}
+	md, err := r.read(r.f, h.MetadataOffset, h.MetadataLength)
+	if err == nil {
+		md, err = decompress(md, h.InternalCompression)
+	}
+	if err != nil {
+		return err
+	}
+	r.metadata, err = parseMetadata(md, h)
+	return err
+}
+
+// acquire returns the file of r for a read,
+// that must be finished by calling release.
+func (r *Reader) acquire() (*os.File, error) {
+	r.fmtx.RLock()
+	defer r.fmtx.RUnlock()
+	if r.f == nil {
+		return nil, mbtiles.ErrClosed
+	}
+	r.wg.Add(1)
+	return r.f, nil
+}
+
+func (r *Reader) release() {
+	r.wg.Done()
+}
+
+// read returns n bytes at off. Ranges beyond the
+// size of the file at open are rejected with errTruncated.
+func (r *Reader) read(f *os.File, off, n uint64) ([]byte, error) {
+	if size := uint64(r.Size); off > size || n > size-off {
+		return nil, errTruncated
+	}
+	b := make([]byte, n)
+	_, err := f.ReadAt(b, int64(off))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (r *Reader) readDirectory(f *os.File, off, n uint64) ([]entry, error) {
+	if n > maxInternalLength {
+		return nil, errDirectory
+	}
+	b, err := r.read(f, off, n)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = decompress(b, r.header.InternalCompression); err != nil {
+		return nil, err
+	}
+	return deserializeDirectory(b)
+}
+
+func (r *Reader) leaf(f *os.File, off, n uint64) ([]entry, error) {
+	r.mtx.Lock()
+	dir, ok := r.leaves[off]
+	r.mtx.Unlock()
+	if ok {
+		return dir, nil
+	}
+	dir, err := r.readDirectory(f, r.header.LeafOffset+off, n)
+	if err != nil {
+		return nil, err
+	}
+	r.mtx.Lock()
+	if len(r.leaves) >= maxLeafCache {
+		r.leaves = make(map[uint64][]entry)
+	}
+	r.leaves[off] = dir
+	r.mtx.Unlock()
+	return dir, nil
+}
+
+// Header returns the header of the archive.
+func (r *Reader) Header() Header {
+	return *r.header
+}
+
+// Metadata returns the metadata of the archive. Header fields are
+// used for bounds, center, zoom range and format if the metadata
+// does not have them.
+func (r *Reader) Metadata() *mbtiles.Metadata {
+	return r.metadata
+}
+
+// ModTime returns the modification time of the archive.
+func (r *Reader) ModTime() time.Time {
+	return r.Mtime
+}
+
+// GetTile returns the data of tile z, x, y in TMS coordinates.
+// The data is returned as stored, gzip compressed vector tiles
+// are not decompressed.
+func (r *Reader) GetTile(z, x, y int) ([]byte, error) {
+	return r.GetTileContext(context.Background(), z, x, y)
+}
+
+// GetTileContext is like GetTile, but fails if ctx is done.
+func (r *Reader) GetTileContext(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := mbtiles.CheckTile(z, x, y); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := r.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer r.release()
+	id := TileID(z, x, tile.FlipY(z, y))
+	dir := r.root
+	for depth := 0; depth < maxDepth; depth++ {
+		e, ok := findTile(dir, id)
+		if !ok {
+			break
+		}
+		if e.RunLength > 0 {
+			data, err := r.read(f, r.header.TileDataOffset+e.Offset, uint64(e.Length))
+			if err == errTruncated || err == io.ErrUnexpectedEOF {
+				err = &mbtiles.TileError{Z: z, X: x, Y: y, Err: mbtiles.ErrCorrupt, Cause: err}
+			}
+			return data, err
+		}
+		if dir, err = r.leaf(f, e.Offset, uint64(e.Length)); err != nil {
+			if err == errDirectory || err == errTruncated || err == io.ErrUnexpectedEOF {
+				err = &mbtiles.TileError{Z: z, X: x, Y: y, Err: mbtiles.ErrCorrupt, Cause: err}
+			}
+			return nil, err
+		}
+	}
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// GetGridData returns ErrTileNotFound, PMTiles archives have no UTFGrids.
+func (r *Reader) GetGridData(z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// GetGridContext returns ErrTileNotFound like GetGridData.
+func (r *Reader) GetGridContext(ctx context.Context, z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// Walk calls fn for each tile of the archive in tile id order
+// with TMS coordinates. Walk stops at the first error returned by fn.
+func (r *Reader) Walk(fn func(z, x, y int, data []byte) error) error {
+	f, err := r.acquire()
+	if err != nil {
+		return err
+	}
+	defer r.release()
+	return r.walk(f, r.root, 0, fn)
+}
+
+func (r *Reader) walk(f *os.File, dir []entry, depth int, fn func(z, x, y int, data []byte) error) error {
+	for _, e := range dir {
+		if e.RunLength == 0 {
+			if depth+1 >= maxDepth {
+				return errDirectory
+			}
+			leaf, err := r.readDirectory(f, r.header.LeafOffset+e.Offset, uint64(e.Length))
+			if err != nil {
+				return err
+			}
+			if err = r.walk(f, leaf, depth+1, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := r.read(f, r.header.TileDataOffset+e.Offset, uint64(e.Length))
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < uint64(e.RunLength); i++ {
+			z, x, y := TileCoord(e.TileID + i)
+			if err = fn(z, x, tile.FlipY(z, y), data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the archive after reads in progress are finished.
+func (r *Reader) Close() error {
+	r.fmtx.Lock()
+	f := r.f
+	r.f = nil
+	r.fmtx.Unlock()
+	if f == nil {
+		return mbtiles.ErrClosed
+	}
+	r.wg.Wait()
+	return f.Close()
+}
+
+// parseMetadata converts the JSON metadata of an archive to
+// MBTiles form. Vector layers and tile stats go to the json key,
+// other values that are not strings are kept in JSON form.
+func parseMetadata(data []byte, h *Header) (*mbtiles.Metadata, error) {
+	var m map[string]json.RawMessage
+	if len(data) != 0 {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	}
+	values := make(map[string]string)
+	js := make(map[string]json.RawMessage)
+	for k, v := range m {
+		switch k {
+		case "vector_layers", "tilestats":
+			js[k] = v
+			continue
+		}
+		var s string
+		if json.Unmarshal(v, &s) == nil {
+			values[k] = s
+		} else {
+			values[k] = string(v)
+		}
+	}
+	if len(js) != 0 {
+		b, err := json.Marshal(js)
+		if err != nil {
+			return nil, err
+		}
+		values["json"] = string(b)
+	}
+
+	fmtE7 := func(v ...int32) string {
+		s := ""
+		for i, x := range v {
+			if i > 0 {
+				s += ","
+			}
+			s += strconv.FormatFloat(float64(x)/1e7, 'f', -1, 64)
+		}
+		return s
+	}
+	setdef := func(k, v string) {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	setdef("bounds", fmtE7(h.MinLonE7, h.MinLatE7, h.MaxLonE7, h.MaxLatE7))
+	setdef("center", fmtE7(h.CenterLonE7, h.CenterLatE7)+","+strconv.Itoa(int(h.CenterZoom)))
+	setdef("minzoom", strconv.Itoa(int(h.MinZoom)))
+	setdef("maxzoom", strconv.Itoa(int(h.MaxZoom)))
+	if f := tileFormat(h.TileType); f != mbtiles.UnknownFormat {
+		setdef("format", f.String())
+	}
+	return mbtiles.ParseMetadata(values), nil
+}
+
+func tileFormat(t uint8) mbtiles.TileFormat {
+	switch t {
+	case MVT:
+		return mbtiles.PBF
+	case PNG:
+		return mbtiles.PNG
+	case JPEG:
+		return mbtiles.JPEG
+	case WebP:
+		return mbtiles.WebP
+	}
+	return mbtiles.UnknownFormat
+}
+
+func tileType(f mbtiles.TileFormat) uint8 {
+	switch f {
+	case mbtiles.PBF:
+		return MVT
+	case mbtiles.PNG:
+		return PNG
+	case mbtiles.JPEG:
+		return JPEG
+	case mbtiles.WebP:
+		return WebP
+	}
+	return UnknownTileType
+}
+
+func e7(v float64) int32 {
+	return int32(math.Round(v * 1e7))
+}
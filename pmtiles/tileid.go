@@ -0,0 +1,61 @@
+package pmtiles
+
+// TileID returns the PMTiles tile id of the tile z, x, y
+// in XYZ coordinates. Ids are ordered by zoom level first,
+// and along a Hilbert curve within a zoom level.
+func TileID(z, x, y int) uint64 {
+	var acc uint64
+	for t := 0; t < z; t++ {
+		acc += uint64(1) << uint(2*t)
+	}
+	n := uint64(1) << uint(z)
+	tx, ty := uint64(x), uint64(y)
+	var d uint64
+	for s := n / 2; s > 0; s /= 2 {
+		var rx, ry uint64
+		if tx&s != 0 {
+			rx = 1
+		}
+		if ty&s != 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		tx, ty = rotate(n, tx, ty, rx, ry)
+	}
+	return acc + d
+}
+
+// TileCoord returns the XYZ coordinates of tile id.
+func TileCoord(id uint64) (z, x, y int) {
+	var acc uint64
+	for z = 0; z < 32; z++ {
+		num := uint64(1) << uint(2*z)
+		if acc+num > id {
+			break
+		}
+		acc += num
+	}
+	n := uint64(1) << uint(z)
+	t := id - acc
+	var tx, ty uint64
+	for s := uint64(1); s < n; s *= 2 {
+		rx := 1 & (t / 2)
+		ry := 1 & (t ^ rx)
+		tx, ty = rotate(s, tx, ty, rx, ry)
+		tx += s * rx
+		ty += s * ry
+		t /= 4
+	}
+	return z, int(tx), int(ty)
+}
+
+func rotate(n, x, y, rx, ry uint64) (uint64, uint64) {
+	if ry == 0 {
+		if rx == 1 {
+			x = n - 1 - x
+			y = n - 1 - y
+		}
+		x, y = y, x
+	}
+	return x, y
+}
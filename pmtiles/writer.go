@@ -0,0 +1,301 @@
+package pmtiles
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var errClosed = errors.New("pmtiles: writer is closed")
+
+// Writer creates a PMTiles v3 archive. Tile data is collected
+// in a temporary file and written in tile id order on Close.
+// Identical tiles are stored once.
+type Writer struct {
+	Filename string
+
+	tmp     *os.File
+	tmpbuf  *bufio.Writer
+	size    uint64
+	entries []entry
+	content map[[md5.Size]byte]int // index of first entry with content
+	seen    map[uint64]bool
+	values  map[string]string
+
+	tileType        uint8
+	tileCompression uint8
+	minz, maxz      int
+}
+
+// Create creates the PMTiles archive fn. It fails if fn exists.
+func Create(fn string) (*Writer, error) {
+	if _, err := os.Stat(fn); err == nil {
+		return nil, &os.PathError{Op: "create", Path: fn, Err: os.ErrExist}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fn), filepath.Base(fn)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		Filename: fn,
+		tmp:      tmp,
+		tmpbuf:   bufio.NewWriter(tmp),
+		content:  make(map[[md5.Size]byte]int),
+		seen:     make(map[uint64]bool),
+		values:   make(map[string]string),
+		minz:     -1,
+	}, nil
+}
+
+// PutTile adds the tile z, x, y in TMS coordinates.
+func (w *Writer) PutTile(z, x, y int, data []byte) error {
+	if w.tmp == nil {
+		return errClosed
+	}
+	if err := mbtiles.CheckTile(z, x, y); err != nil {
+		return err
+	}
+	id := TileID(z, x, tile.FlipY(z, y))
+	if w.seen[id] {
+		return errors.New("pmtiles: duplicate tile")
+	}
+	w.seen[id] = true
+
+	if w.tileType == UnknownTileType {
+		f, c := mbtiles.DetectFormat(data)
+		w.tileType = tileType(f)
+		w.tileCompression = NoCompression
+		if c == mbtiles.Gzip {
+			w.tileCompression = Gzip
+		}
+	}
+	if w.minz < 0 || z < w.minz {
+		w.minz = z
+	}
+	if z > w.maxz {
+		w.maxz = z
+	}
+
+	sum := md5.Sum(data)
+	if i, ok := w.content[sum]; ok {
+		e := w.entries[i]
+		w.entries = append(w.entries, entry{TileID: id, Offset: e.Offset, Length: e.Length, RunLength: 1})
+		return nil
+	}
+	if _, err := w.tmpbuf.Write(data); err != nil {
+		return err
+	}
+	w.content[sum] = len(w.entries)
+	w.entries = append(w.entries, entry{TileID: id, Offset: w.size, Length: uint32(len(data)), RunLength: 1})
+	w.size += uint64(len(data))
+	return nil
+}
+
+// SetMetadata sets the metadata value for name.
+func (w *Writer) SetMetadata(name, value string) error {
+	w.values[name] = value
+	return nil
+}
+
+// WriteMetadata sets metadata values from md.
+func (w *Writer) WriteMetadata(md *mbtiles.Metadata) error {
+	for k, v := range md.Values() {
+		w.values[k] = v
+	}
+	return nil
+}
+
+// Close writes the archive and removes the temporary file.
+func (w *Writer) Close() error {
+	if w.tmp == nil {
+		return errClosed
+	}
+	err := w.tmpbuf.Flush()
+	if err == nil {
+		err = w.write()
+	}
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+	w.tmp = nil
+	if err != nil {
+		os.Remove(w.Filename)
+	}
+	return err
+}
+
+func (w *Writer) write() error {
+	sort.Slice(w.entries, func(i, j int) bool {
+		return w.entries[i].TileID < w.entries[j].TileID
+	})
+
+	// assign offsets in tile id order, and merge runs of identical tiles
+	var entries []entry
+	newoff := make(map[uint64]uint64) // offset in tmp to offset in archive
+	var size uint64
+	for _, e := range w.entries {
+		off, ok := newoff[e.Offset]
+		if !ok {
+			off = size
+			newoff[e.Offset] = off
+			size += uint64(e.Length)
+		}
+		if n := len(entries); n > 0 {
+			last := &entries[n-1]
+			if last.Offset == off && last.TileID+uint64(last.RunLength) == e.TileID {
+				last.RunLength++
+				continue
+			}
+		}
+		entries = append(entries, entry{TileID: e.TileID, Offset: off, Length: e.Length, RunLength: 1})
+	}
+
+	root, leaves, err := buildDirectories(entries)
+	if err != nil {
+		return err
+	}
+	meta, err := w.metadataJSON()
+	if err != nil {
+		return err
+	}
+	if meta, err = compress(meta, Gzip); err != nil {
+		return err
+	}
+
+	h := w.header()
+	h.RootOffset = HeaderLen
+	h.RootLength = uint64(len(root))
+	h.MetadataOffset = h.RootOffset + h.RootLength
+	h.MetadataLength = uint64(len(meta))
+	h.LeafOffset = h.MetadataOffset + h.MetadataLength
+	h.LeafLength = uint64(len(leaves))
+	h.TileDataOffset = h.LeafOffset + h.LeafLength
+	h.TileDataLength = size
+	h.AddressedTiles = uint64(len(w.entries))
+	h.TileEntries = uint64(len(entries))
+	h.TileContents = uint64(len(newoff))
+
+	f, err := os.OpenFile(w.Filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	for _, b := range [][]byte{h.bytes(), root, meta, leaves} {
+		if _, err = bw.Write(b); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	// copy tile contents in archive order
+	var pos uint64
+	for _, e := range w.entries {
+		if newoff[e.Offset] != pos {
+			continue
+		}
+		_, err = io.Copy(bw, io.NewSectionReader(w.tmp, int64(e.Offset), int64(e.Length)))
+		if err != nil {
+			f.Close()
+			return err
+		}
+		pos += uint64(e.Length)
+	}
+	if err = bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// buildDirectories returns the compressed root directory, and the leaf
+// directories if the entries don't fit into the root.
+func buildDirectories(entries []entry) (root, leaves []byte, err error) {
+	root, err = compress(serializeDirectory(entries), Gzip)
+	if err != nil || len(root) <= rootLimit-HeaderLen {
+		return root, nil, err
+	}
+	for leafSize := 4096; ; leafSize *= 2 {
+		var rootEntries []entry
+		leaves = leaves[:0]
+		for i := 0; i < len(entries); i += leafSize {
+			j := i + leafSize
+			if j > len(entries) {
+				j = len(entries)
+			}
+			leaf, err := compress(serializeDirectory(entries[i:j]), Gzip)
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, entry{
+				TileID: entries[i].TileID,
+				Offset: uint64(len(leaves)),
+				Length: uint32(len(leaf)),
+			})
+			leaves = append(leaves, leaf...)
+		}
+		root, err = compress(serializeDirectory(rootEntries), Gzip)
+		if err != nil || len(root) <= rootLimit-HeaderLen {
+			return root, leaves, err
+		}
+	}
+}
+
+// metadataJSON returns the metadata values as a JSON object.
+// Members of the json key are moved to the top level.
+func (w *Writer) metadataJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	for k, v := range w.values {
+		m[k] = v
+	}
+	if js, ok := w.values["json"]; ok {
+		var jm map[string]json.RawMessage
+		if json.Unmarshal([]byte(js), &jm) == nil {
+			delete(m, "json")
+			for k, v := range jm {
+				m[k] = v
+			}
+		}
+	}
+	return json.Marshal(m)
+}
+
+// header returns the header with fields derived from
+// the tiles and metadata.
+func (w *Writer) header() *Header {
+	h := &Header{
+		Clustered:           true,
+		InternalCompression: Gzip,
+		TileCompression:     w.tileCompression,
+		TileType:            w.tileType,
+	}
+	md := mbtiles.ParseMetadata(w.values)
+	if f := md.TileFormat(); f != mbtiles.UnknownFormat {
+		h.TileType = tileType(f)
+	}
+	if h.TileCompression == UnknownCompression {
+		h.TileCompression = NoCompression
+	}
+	if w.minz >= 0 {
+		h.MinZoom, h.MaxZoom = uint8(w.minz), uint8(w.maxz)
+	}
+	b := md.Bounds
+	if b == (mbtiles.MbtBounds{}) {
+		b = mbtiles.MbtBounds{W: -180, S: -tile.MaxLat, E: 180, N: tile.MaxLat}
+	}
+	h.MinLonE7, h.MinLatE7 = e7(b.W), e7(b.S)
+	h.MaxLonE7, h.MaxLatE7 = e7(b.E), e7(b.N)
+	c := md.Center
+	if c == (mbtiles.MbtCenter{}) {
+		c = mbtiles.MbtCenter{Lon: (b.W + b.E) / 2, Lat: (b.S + b.N) / 2, Zoom: float64(h.MinZoom)}
+	}
+	h.CenterLonE7, h.CenterLatE7 = e7(c.Lon), e7(c.Lat)
+	h.CenterZoom = uint8(c.Zoom)
+	return h
+}
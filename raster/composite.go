@@ -0,0 +1,224 @@
+package raster
+
+import (
+	"context"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layer is a tileset of a Composite.
+type Layer struct {
+	Source  mbtiles.TileSource
+	Opacity float64 // 0 to 1
+}
+
+// Composite is a TileSource with PNG tiles alpha-blended from the
+// raster tiles of its layers, the first layer at the bottom. PNG tiles
+// present in a single fully opaque layer are returned unchanged,
+// tiles of other formats are converted to PNG.
+// UTFGrids of the layers are not provided.
+type Composite struct {
+	Name   string
+	Layers []Layer
+}
+
+// NewComposite returns the composite of layers named name.
+func NewComposite(name string, layers []Layer) *Composite {
+	return &Composite{Name: name, Layers: layers}
+}
+
+// GetTile returns the composite tile z, x, y in TMS coordinates.
+func (c *Composite) GetTile(z, x, y int) ([]byte, error) {
+	return c.GetTileContext(context.Background(), z, x, y)
+}
+
+// GetTileContext returns the composite tile z, x, y like GetTile
+// using ctx for the lookups in the layers.
+func (c *Composite) GetTileContext(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := mbtiles.CheckTile(z, x, y); err != nil {
+		return nil, err
+	}
+	var found []int // layers with the tile
+	tiles := make([][]byte, len(c.Layers))
+	for i, l := range c.Layers {
+		if l.Opacity <= 0 {
+			continue
+		}
+		data, err := mbtiles.GetTileContext(ctx, l.Source, z, x, y)
+		if errors.Is(err, mbtiles.ErrTileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tiles[i] = data
+		found = append(found, i)
+	}
+	switch {
+	case len(found) == 0:
+		return nil, mbtiles.ErrTileNotFound
+	case len(found) == 1 && c.Layers[found[0]].Opacity >= 1 && ispng(tiles[found[0]]):
+		return tiles[found[0]], nil
+	}
+
+	layers := make([][]byte, len(found))
+	opacity := make([]float64, len(found))
+	for j, i := range found {
+		layers[j], opacity[j] = tiles[i], c.Layers[i].Opacity
+	}
+	im, err := blend(layers, opacity)
+	if err != nil {
+		return nil, &mbtiles.TileError{Z: z, X: x, Y: y, Err: mbtiles.ErrCorrupt, Cause: err}
+	}
+	return Encode(im, mbtiles.PNG)
+}
+
+// ispng reports if data is an uncompressed PNG tile.
+func ispng(data []byte) bool {
+	f, c := mbtiles.DetectFormat(data)
+	return f == mbtiles.PNG && c == mbtiles.Uncompressed
+}
+
+// Blend returns the PNG tile alpha-blended from the raster tiles, the
+// first one at the bottom. Tiles are scaled to the size of the first one.
+func Blend(tiles ...[]byte) ([]byte, error) {
+	return BlendFormat(mbtiles.PNG, tiles...)
+}
+
+// BlendFormat returns the tile blended like Blend encoded in format f.
+// Like Recompress, it leaves transparent results alone for JPEG and
+// returns nil data then.
+func BlendFormat(f mbtiles.TileFormat, tiles ...[]byte) ([]byte, error) {
+	if !CanEncode(f) {
+		return nil, ErrFormat
+	}
+	im, err := blend(tiles, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f == mbtiles.JPEG && !opaque(im) {
+		return nil, nil
+	}
+	return Encode(im, f)
+}
+
+// blend draws the tiles over each other with the given opacities,
+// or fully opaque if opacity is nil.
+func blend(tiles [][]byte, opacity []float64) (*image.RGBA, error) {
+	var dst *image.RGBA
+	for i, data := range tiles {
+		im, _, err := Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		b := im.Bounds()
+		if dst == nil {
+			dst = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		}
+		if b.Size() != dst.Rect.Size() {
+			scaled := image.NewRGBA(dst.Rect)
+			draw.BiLinear.Scale(scaled, scaled.Rect, im, b, draw.Src, nil)
+			im, b = scaled, scaled.Rect
+		}
+		var mask image.Image
+		if opacity != nil && opacity[i] < 1 {
+			mask = image.NewUniform(color.Alpha{uint8(opacity[i]*255 + 0.5)})
+		}
+		draw.DrawMask(dst, dst.Rect, im, b.Min, mask, image.Point{}, draw.Over)
+	}
+	if dst == nil {
+		return nil, errors.New("raster: no tiles to blend")
+	}
+	return dst, nil
+}
+
+// GetGridData reports all grids missing.
+func (c *Composite) GetGridData(z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// GetGridContext reports all grids missing.
+func (c *Composite) GetGridContext(ctx context.Context, z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+// Metadata returns the metadata of the composite. Its bounds
+// and zoom range are the union of those of the layers.
+func (c *Composite) Metadata() *mbtiles.Metadata {
+	values := map[string]string{
+		"name":   c.Name,
+		"format": mbtiles.PNG.String(),
+		"type":   "baselayer",
+	}
+	var descr, attr []string
+	b := mbtiles.MbtBounds{W: math.Inf(1), S: math.Inf(1), E: math.Inf(-1), N: math.Inf(-1)}
+	hasbounds, haszoom := true, true
+	minz, maxz := math.MaxInt32, 0
+	for _, l := range c.Layers {
+		md := l.Source.Metadata()
+		v := md.Values()
+		if md.Bounds == (mbtiles.MbtBounds{}) {
+			hasbounds = false
+		}
+		lb := md.Bounds
+		b.W, b.S = math.Min(b.W, lb.W), math.Min(b.S, lb.S)
+		b.E, b.N = math.Max(b.E, lb.E), math.Max(b.N, lb.N)
+		if v["minzoom"] == "" || v["maxzoom"] == "" {
+			haszoom = false
+		}
+		if md.MinZoom < minz {
+			minz = md.MinZoom
+		}
+		if md.MaxZoom > maxz {
+			maxz = md.MaxZoom
+		}
+		if md.Name != "" {
+			descr = append(descr, md.Name)
+		}
+		if md.Attribution != "" {
+			attr = append(attr, md.Attribution)
+		}
+	}
+	if len(c.Layers) == 0 {
+		hasbounds, haszoom = false, false
+	}
+	if hasbounds {
+		values["bounds"] = strconv.FormatFloat(b.W, 'f', -1, 64) + "," +
+			strconv.FormatFloat(b.S, 'f', -1, 64) + "," +
+			strconv.FormatFloat(b.E, 'f', -1, 64) + "," +
+			strconv.FormatFloat(b.N, 'f', -1, 64)
+		values["center"] = strconv.FormatFloat((b.W+b.E)/2, 'f', -1, 64) + "," +
+			strconv.FormatFloat((b.S+b.N)/2, 'f', -1, 64) + "," + strconv.Itoa(minz)
+	}
+	if haszoom {
+		values["minzoom"] = strconv.Itoa(minz)
+		values["maxzoom"] = strconv.Itoa(maxz)
+	}
+	if len(descr) != 0 {
+		values["description"] = "Composite of " + strings.Join(descr, ", ")
+	}
+	if len(attr) != 0 {
+		values["attribution"] = strings.Join(attr, " | ")
+	}
+	return mbtiles.ParseMetadata(values)
+}
+
+// ModTime returns the latest modification time of the layers.
+func (c *Composite) ModTime() time.Time {
+	var t time.Time
+	for _, l := range c.Layers {
+		if lt := l.Source.ModTime(); lt.After(t) {
+			t = lt
+		}
+	}
+	return t
+}
+
+var _ mbtiles.ContextTileSource = (*Composite)(nil)
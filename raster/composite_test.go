@@ -0,0 +1,204 @@
+package raster
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+// memsource is a TileSource with tiles in memory.
+type memsource struct {
+	tiles  map[[3]int][]byte
+	values map[string]string
+	mtime  time.Time
+}
+
+func (m *memsource) GetTile(z, x, y int) ([]byte, error) {
+	if data, ok := m.tiles[[3]int{z, x, y}]; ok {
+		return data, nil
+	}
+	return nil, mbtiles.ErrTileNotFound
+}
+
+func (m *memsource) GetGridData(z, x, y int, callback string) ([]byte, error) {
+	return nil, mbtiles.ErrTileNotFound
+}
+
+func (m *memsource) Metadata() *mbtiles.Metadata {
+	return mbtiles.ParseMetadata(m.values)
+}
+
+func (m *memsource) ModTime() time.Time {
+	return m.mtime
+}
+
+// solid returns a tile of size pixels filled with c encoded in format f.
+func solid(t *testing.T, f mbtiles.TileFormat, size int, c color.Color) []byte {
+	im := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			im.Set(x, y, c)
+		}
+	}
+	data, err := Encode(im, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// pixel returns the color at the center of the tile data.
+func pixel(t *testing.T, data []byte) color.NRGBA {
+	im, _, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := im.Bounds()
+	return color.NRGBAModel.Convert(im.At(b.Dx()/2, b.Dy()/2)).(color.NRGBA)
+}
+
+func near(a, b color.NRGBA) bool {
+	d := func(u, v uint8) bool { return int(u) <= int(v)+2 && int(v) <= int(u)+2 }
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && d(a.A, b.A)
+}
+
+var (
+	red  = color.NRGBA{255, 0, 0, 255}
+	blue = color.NRGBA{0, 0, 255, 255}
+)
+
+func TestComposite(t *testing.T) {
+	redpng := solid(t, mbtiles.PNG, 16, red)
+	bottom := &memsource{tiles: map[[3]int][]byte{
+		{0, 0, 0}: redpng,
+		{1, 0, 0}: redpng,
+		{1, 1, 0}: solid(t, mbtiles.JPEG, 16, red),
+	}}
+	top := &memsource{tiles: map[[3]int][]byte{
+		{1, 0, 0}: solid(t, mbtiles.PNG, 32, blue),
+		{1, 0, 1}: solid(t, mbtiles.PNG, 16, blue),
+		{1, 1, 1}: solid(t, mbtiles.PNG, 16, blue),
+	}}
+	hidden := &memsource{tiles: map[[3]int][]byte{
+		{1, 1, 1}: []byte("not an image"),
+	}}
+	c := NewComposite("c", []Layer{{bottom, 1}, {top, 0.5}, {hidden, 0}})
+
+	tests := []struct {
+		z, x, y int
+		want    color.NRGBA
+		size    int
+	}{
+		{1, 0, 0, color.NRGBA{127, 0, 128, 255}, 16}, // scaled to the bottom tile
+		{1, 1, 0, red, 16},                           // converted from JPEG
+		{1, 0, 1, color.NRGBA{0, 0, 255, 128}, 16},   // single translucent layer
+		{1, 1, 1, color.NRGBA{0, 0, 255, 128}, 16},   // hidden layer not read
+	}
+	for _, tt := range tests {
+		data, err := c.GetTile(tt.z, tt.x, tt.y)
+		if err != nil {
+			t.Errorf("tile %d/%d/%d: %v", tt.z, tt.x, tt.y, err)
+			continue
+		}
+		im, f, err := Decode(data)
+		if err != nil || f != mbtiles.PNG {
+			t.Errorf("tile %d/%d/%d: got %v, %v, want PNG", tt.z, tt.x, tt.y, f, err)
+			continue
+		}
+		if s := im.Bounds().Dx(); s != tt.size {
+			t.Errorf("tile %d/%d/%d: got size %d, want %d", tt.z, tt.x, tt.y, s, tt.size)
+		}
+		if got := pixel(t, data); !near(got, tt.want) {
+			t.Errorf("tile %d/%d/%d: got %v, want %v", tt.z, tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	// a PNG in a single opaque layer is passed through
+	if data, err := c.GetTile(0, 0, 0); err != nil || !bytes.Equal(data, redpng) {
+		t.Errorf("single PNG: got %d bytes, %v", len(data), err)
+	}
+	if _, err := c.GetTile(1, 1, 2); !errors.Is(err, mbtiles.ErrOutOfRange) {
+		t.Errorf("out of range: got %v, want %v", err, mbtiles.ErrOutOfRange)
+	}
+	if _, err := c.GetTile(2, 0, 0); err != mbtiles.ErrTileNotFound {
+		t.Errorf("missing tile: got %v, want %v", err, mbtiles.ErrTileNotFound)
+	}
+	hidden.tiles[[3]int{1, 1, 1}] = []byte("still not an image")
+	c.Layers[2].Opacity = 1
+	if _, err := c.GetTile(1, 1, 1); !errors.Is(err, mbtiles.ErrCorrupt) {
+		t.Errorf("corrupt tile: got %v, want %v", err, mbtiles.ErrCorrupt)
+	}
+}
+
+func TestBlendFormat(t *testing.T) {
+	redpng := solid(t, mbtiles.PNG, 16, red)
+	half := solid(t, mbtiles.PNG, 16, color.NRGBA{0, 0, 255, 128})
+
+	data, err := BlendFormat(mbtiles.JPEG, redpng, half)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := mbtiles.DetectFormat(data); f != mbtiles.JPEG {
+		t.Errorf("opaque result: got %v, want %v", f, mbtiles.JPEG)
+	}
+	if got := pixel(t, data); got.R < 110 || got.R > 145 || got.B < 110 || got.B > 145 {
+		t.Errorf("opaque result: got %v", got)
+	}
+	if data, err := BlendFormat(mbtiles.JPEG, half); data != nil || err != nil {
+		t.Errorf("transparent result: got %d bytes, %v, want none", len(data), err)
+	}
+	if data, err := Blend(half); err != nil || !near(pixel(t, data), color.NRGBA{0, 0, 255, 128}) {
+		t.Errorf("transparent PNG: got %v, %v", pixel(t, data), err)
+	}
+	if _, err := BlendFormat(mbtiles.PBF, redpng); err != ErrFormat {
+		t.Errorf("vector format: got %v, want %v", err, ErrFormat)
+	}
+}
+
+func TestCompositeMetadata(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &memsource{values: map[string]string{
+		"name": "a", "attribution": "© A",
+		"bounds": "-10,-20,10,20", "minzoom": "2", "maxzoom": "8",
+	}, mtime: t0}
+	b := &memsource{values: map[string]string{
+		"name": "b", "bounds": "0,-30,30,10", "minzoom": "0", "maxzoom": "5",
+	}, mtime: t0.Add(time.Hour)}
+	c := NewComposite("c", []Layer{{a, 1}, {b, 1}})
+	want := map[string]string{
+		"name":        "c",
+		"format":      "png",
+		"type":        "baselayer",
+		"bounds":      "-10,-30,30,20",
+		"center":      "10,-5,0",
+		"minzoom":     "0",
+		"maxzoom":     "8",
+		"description": "Composite of a, b",
+		"attribution": "© A",
+	}
+	got := c.Metadata().Values()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if mt := c.ModTime(); !mt.Equal(t0.Add(time.Hour)) {
+		t.Errorf("mtime: got %v", mt)
+	}
+
+	// a layer without bounds or zoom range leaves them undefined
+	c.Layers = append(c.Layers, Layer{&memsource{values: map[string]string{"name": "d"}}, 1})
+	got = c.Metadata().Values()
+	for _, k := range []string{"bounds", "center", "minzoom", "maxzoom"} {
+		if v, ok := got[k]; ok {
+			t.Errorf("%s: got %q, want none", k, v)
+		}
+	}
+}
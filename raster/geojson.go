@@ -0,0 +1,225 @@
+package raster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Overlay holds GeoJSON features to draw on static maps.
+//
+// Feature styles are taken from the simplestyle properties stroke,
+// stroke-width, stroke-opacity, fill, fill-opacity and marker-color.
+type Overlay struct {
+	shapes []shape
+}
+
+const (
+	shapePoint = iota
+	shapeLine
+	shapePolygon
+)
+
+// shape is a point, line or polygon with lon/lat coordinates.
+type shape struct {
+	kind  int
+	paths [][][2]float64 // polygon rings or a single line or point
+	style style
+}
+
+type style struct {
+	stroke, fill, marker color.NRGBA
+	width                float64
+}
+
+var defaultStyle = style{
+	stroke: color.NRGBA{0x55, 0x55, 0x55, 0xff},
+	fill:   color.NRGBA{0x55, 0x55, 0x55, 0x99},
+	marker: color.NRGBA{0x7e, 0x7e, 0x7e, 0xff},
+	width:  2,
+}
+
+type geoObject struct {
+	Type        string                 `json:"type"`
+	Features    []*geoObject           `json:"features"`
+	Geometry    *geoObject             `json:"geometry"`
+	Geometries  []*geoObject           `json:"geometries"`
+	Properties  map[string]interface{} `json:"properties"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+// ParseGeoJSON parses a GeoJSON feature collection,
+// feature or geometry.
+func ParseGeoJSON(data []byte) (*Overlay, error) {
+	var obj geoObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	o := new(Overlay)
+	if err := o.add(&obj, defaultStyle); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *Overlay) add(obj *geoObject, st style) error {
+	if obj == nil {
+		return nil
+	}
+	var err error
+	switch obj.Type {
+	case "FeatureCollection":
+		for _, f := range obj.Features {
+			if err = o.add(f, st); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Feature":
+		if st, err = parseStyle(obj.Properties); err != nil {
+			return err
+		}
+		return o.add(obj.Geometry, st)
+	case "GeometryCollection":
+		for _, g := range obj.Geometries {
+			if err = o.add(g, st); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Point":
+		var c []float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			err = o.addShapes(shapePoint, st, [][][]float64{{c}})
+		}
+	case "MultiPoint":
+		var c [][]float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			for _, p := range c {
+				if err = o.addShapes(shapePoint, st, [][][]float64{{p}}); err != nil {
+					break
+				}
+			}
+		}
+	case "LineString":
+		var c [][]float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			err = o.addShapes(shapeLine, st, [][][]float64{c})
+		}
+	case "MultiLineString":
+		var c [][][]float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			for _, l := range c {
+				if err = o.addShapes(shapeLine, st, [][][]float64{l}); err != nil {
+					break
+				}
+			}
+		}
+	case "Polygon":
+		var c [][][]float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			err = o.addShapes(shapePolygon, st, c)
+		}
+	case "MultiPolygon":
+		var c [][][][]float64
+		if err = json.Unmarshal(obj.Coordinates, &c); err == nil {
+			for _, poly := range c {
+				if err = o.addShapes(shapePolygon, st, poly); err != nil {
+					break
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("raster: unknown GeoJSON type %q", obj.Type)
+	}
+	return err
+}
+
+func (o *Overlay) addShapes(kind int, st style, paths [][][]float64) error {
+	s := shape{kind: kind, style: st}
+	for _, path := range paths {
+		v := make([][2]float64, len(path))
+		for i, p := range path {
+			if len(p) < 2 {
+				return errors.New("raster: invalid GeoJSON position")
+			}
+			v[i] = [2]float64{p[0], p[1]}
+		}
+		s.paths = append(s.paths, v)
+	}
+	o.shapes = append(o.shapes, s)
+	return nil
+}
+
+// parseStyle returns the style from simplestyle feature properties.
+func parseStyle(props map[string]interface{}) (style, error) {
+	st := defaultStyle
+	str := func(k string) (string, bool) {
+		s, ok := props[k].(string)
+		return s, ok
+	}
+	num := func(k string) (float64, bool) {
+		switch v := props[k].(type) {
+		case float64:
+			return v, true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		}
+		return 0, false
+	}
+	var err error
+	if s, ok := str("stroke"); ok {
+		if st.stroke, err = ParseColor(s); err != nil {
+			return st, err
+		}
+	}
+	if s, ok := str("fill"); ok {
+		if st.fill, err = ParseColor(s); err != nil {
+			return st, err
+		}
+		st.fill.A = defaultStyle.fill.A
+	}
+	if s, ok := str("marker-color"); ok {
+		if st.marker, err = ParseColor(s); err != nil {
+			return st, err
+		}
+	}
+	if v, ok := num("stroke-width"); ok && v >= 0 {
+		st.width = v
+	}
+	if v, ok := num("stroke-opacity"); ok {
+		st.stroke.A = opacity(v)
+	}
+	if v, ok := num("fill-opacity"); ok {
+		st.fill.A = opacity(v)
+	}
+	return st, nil
+}
+
+func opacity(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+// ParseColor parses a color in #rgb or #rrggbb format,
+// the leading # is optional.
+func ParseColor(s string) (color.NRGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil || len(h) != 6 {
+		return color.NRGBA{}, fmt.Errorf("raster: invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
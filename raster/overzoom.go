@@ -0,0 +1,43 @@
+package raster
+
+import (
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"golang.org/x/image/draw"
+	"image"
+)
+
+// Overzoom returns the part of the tile data covering its descendant
+// dz zoom levels deeper at column x and row y relative to the tile,
+// counted from the top left, scaled up to the size of the tile.
+// The result has the format of data, except for WebP tiles
+// that are encoded as PNG.
+func Overzoom(data []byte, dz, x, y int) ([]byte, error) {
+	im, f, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := Crop(im, dz, x, y)
+	if err != nil {
+		return nil, err
+	}
+	if f == mbtiles.WebP {
+		f = mbtiles.PNG
+	}
+	return Encode(sub, f)
+}
+
+// Crop returns the part of im covering its descendant dz zoom levels
+// deeper at column x and row y, scaled up to the size of im.
+func Crop(im image.Image, dz, x, y int) (image.Image, error) {
+	b := im.Bounds()
+	w, h := b.Dx()>>uint(dz), b.Dy()>>uint(dz)
+	n := 1 << uint(dz)
+	if dz < 0 || w == 0 || h == 0 || x < 0 || y < 0 || x >= n || y >= n {
+		return nil, errors.New("raster: invalid overzoom tile")
+	}
+	sr := image.Rect(x*w, y*h, (x+1)*w, (y+1)*h).Add(b.Min)
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.BiLinear.Scale(dst, dst.Bounds(), im, sr, draw.Src, nil)
+	return dst, nil
+}
@@ -0,0 +1,208 @@
+package raster
+
+// painting of vector shapes
+
+import (
+	"golang.org/x/image/vector"
+	"image"
+	"image/color"
+	"math"
+)
+
+type point struct {
+	x, y float64
+}
+
+// painter draws shapes on an image. Shapes are clipped to the
+// image extended by a margin to keep the rasterizer accurate.
+type painter struct {
+	dst    *image.RGBA
+	r      *vector.Rasterizer
+	lo, hi point // clip rectangle
+}
+
+func newPainter(dst *image.RGBA) *painter {
+	const margin = 64
+	s := dst.Bounds().Size()
+	return &painter{
+		dst: dst,
+		r:   vector.NewRasterizer(s.X, s.Y),
+		lo:  point{-margin, -margin},
+		hi:  point{float64(s.X + margin), float64(s.Y + margin)},
+	}
+}
+
+func (p *painter) begin() {
+	s := p.dst.Bounds().Size()
+	p.r.Reset(s.X, s.Y)
+}
+
+func (p *painter) end(c color.Color) {
+	p.r.Draw(p.dst, p.dst.Bounds(), image.NewUniform(c), image.Point{})
+}
+
+// add adds the polygon to the rasterizer. The rasterizer adds up the
+// signed coverage of overlapping polygons, so polygons with orientation
+// hole cut out of others.
+func (p *painter) add(poly []point, hole bool) {
+	if len(poly) < 3 {
+		return
+	}
+	rev := (signedArea(poly) < 0) != hole
+	for i := range poly {
+		q := poly[i]
+		if rev {
+			q = poly[len(poly)-1-i]
+		}
+		if i == 0 {
+			p.r.MoveTo(float32(q.x), float32(q.y))
+		} else {
+			p.r.LineTo(float32(q.x), float32(q.y))
+		}
+	}
+	p.r.ClosePath()
+}
+
+// fill fills a polygon, its first ring is the outer boundary,
+// the rest are holes.
+func (p *painter) fill(rings [][]point, c color.Color) {
+	p.begin()
+	for i, ring := range rings {
+		p.add(clipPolygon(ring, p.lo, p.hi), i != 0)
+	}
+	p.end(c)
+}
+
+// stroke draws line with the given width,
+// connecting its ends if closed is true.
+func (p *painter) stroke(line []point, width float64, closed bool, c color.Color) {
+	if len(line) == 0 {
+		return
+	}
+	if closed {
+		line = append(line[:len(line):len(line)], line[0])
+	}
+	hw := width / 2
+	p.begin()
+	for i := 0; i+1 < len(line); i++ {
+		a, b, ok := clipSegment(line[i], line[i+1], p.lo, p.hi)
+		if !ok {
+			continue
+		}
+		dx, dy := b.x-a.x, b.y-a.y
+		d := math.Hypot(dx, dy)
+		if d == 0 {
+			continue
+		}
+		nx, ny := -dy/d*hw, dx/d*hw
+		p.add([]point{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}}, false)
+	}
+	// round joins and caps
+	for _, q := range line {
+		if p.lo.x <= q.x && q.x <= p.hi.x && p.lo.y <= q.y && q.y <= p.hi.y {
+			p.add(circle(q, hw), false)
+		}
+	}
+	p.end(c)
+}
+
+// disc draws a filled circle.
+func (p *painter) disc(c point, r float64, col color.Color) {
+	p.begin()
+	p.add(circle(c, r), false)
+	p.end(col)
+}
+
+func circle(c point, r float64) []point {
+	const n = 24
+	v := make([]point, n)
+	for i := range v {
+		a := 2 * math.Pi * float64(i) / n
+		v[i] = point{c.x + r*math.Cos(a), c.y + r*math.Sin(a)}
+	}
+	return v
+}
+
+// signedArea returns twice the signed area of poly.
+func signedArea(poly []point) float64 {
+	var a float64
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return a
+}
+
+// clipSegment clips the segment a-b to the rectangle
+// lo - hi using the Liang-Barsky algorithm.
+func clipSegment(a, b, lo, hi point) (point, point, bool) {
+	dx, dy := b.x-a.x, b.y-a.y
+	t0, t1 := 0.0, 1.0
+	for _, c := range [4][2]float64{
+		{-dx, a.x - lo.x},
+		{dx, hi.x - a.x},
+		{-dy, a.y - lo.y},
+		{dy, hi.y - a.y},
+	} {
+		if c[0] == 0 {
+			if c[1] < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		r := c[1] / c[0]
+		if c[0] < 0 {
+			t0 = math.Max(t0, r)
+		} else {
+			t1 = math.Min(t1, r)
+		}
+	}
+	if t0 > t1 {
+		return a, b, false
+	}
+	return point{a.x + t0*dx, a.y + t0*dy}, point{a.x + t1*dx, a.y + t1*dy}, true
+}
+
+// clipPolygon clips the polygon to the rectangle lo - hi
+// using the Sutherland-Hodgman algorithm.
+func clipPolygon(poly []point, lo, hi point) []point {
+	edges := []struct {
+		inside func(p point) bool
+		cross  func(p, q point) point
+	}{
+		{func(p point) bool { return p.x >= lo.x }, func(p, q point) point { return atX(p, q, lo.x) }},
+		{func(p point) bool { return p.x <= hi.x }, func(p, q point) point { return atX(p, q, hi.x) }},
+		{func(p point) bool { return p.y >= lo.y }, func(p, q point) point { return atY(p, q, lo.y) }},
+		{func(p point) bool { return p.y <= hi.y }, func(p, q point) point { return atY(p, q, hi.y) }},
+	}
+	v := poly
+	for _, e := range edges {
+		if len(v) == 0 {
+			break
+		}
+		in := v
+		v = nil
+		prev := in[len(in)-1]
+		for _, q := range in {
+			switch {
+			case e.inside(q):
+				if !e.inside(prev) {
+					v = append(v, e.cross(prev, q))
+				}
+				v = append(v, q)
+			case e.inside(prev):
+				v = append(v, e.cross(prev, q))
+			}
+			prev = q
+		}
+	}
+	return v
+}
+
+func atX(p, q point, x float64) point {
+	return point{x, p.y + (x-p.x)/(q.x-p.x)*(q.y-p.y)}
+}
+
+func atY(p, q point, y float64) point {
+	return point{p.x + (y-p.y)/(q.y-p.y)*(q.x-p.x), y}
+}
@@ -0,0 +1,146 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Quantize returns im converted to a paletted image with at most n colors,
+// n is clamped to 1..256. The palette is chosen with the median cut algorithm.
+func Quantize(im image.Image, n int) *image.Paletted {
+	if n < 1 {
+		n = 1
+	} else if n > 256 {
+		n = 256
+	}
+	b := im.Bounds()
+	hist := make(map[color.NRGBA]int)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			hist[nrgba(im.At(x, y))]++
+		}
+	}
+	colors := make([]qcolor, 0, len(hist))
+	for c, cnt := range hist {
+		colors = append(colors, qcolor{c, cnt})
+	}
+
+	boxes := []qbox{colors}
+	for len(boxes) < n {
+		// split the box with the widest channel range
+		bi, ch, w := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, cw := box.widest(); cw > w {
+				bi, ch, w = i, c, cw
+			}
+		}
+		if bi < 0 {
+			break
+		}
+		lo, hi := boxes[bi].split(ch)
+		boxes[bi] = lo
+		boxes = append(boxes, hi)
+	}
+
+	pal := make(color.Palette, len(boxes))
+	index := make(map[color.NRGBA]uint8, len(hist))
+	for i, box := range boxes {
+		pal[i] = box.average()
+		for _, c := range box {
+			index[c.c] = uint8(i)
+		}
+	}
+	p := image.NewPaletted(b, pal)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p.SetColorIndex(x, y, index[nrgba(im.At(x, y))])
+		}
+	}
+	return p
+}
+
+// nrgba returns c as non-premultiplied color,
+// with all transparent colors mapped to the same value.
+func nrgba(c color.Color) color.NRGBA {
+	v := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if v.A == 0 {
+		return color.NRGBA{}
+	}
+	return v
+}
+
+// qcolor is a color with its pixel count.
+type qcolor struct {
+	c color.NRGBA
+	n int
+}
+
+func (q qcolor) channel(ch int) uint8 {
+	switch ch {
+	case 0:
+		return q.c.R
+	case 1:
+		return q.c.G
+	case 2:
+		return q.c.B
+	}
+	return q.c.A
+}
+
+// qbox is a set of colors sharing a palette entry.
+type qbox []qcolor
+
+// widest returns the channel with the largest range and its width.
+func (b qbox) widest() (ch, w int) {
+	for c := 0; c < 4; c++ {
+		lo, hi := 255, 0
+		for _, q := range b {
+			v := int(q.channel(c))
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > w {
+			ch, w = c, hi-lo
+		}
+	}
+	return ch, w
+}
+
+// split splits b at the weighted median along channel ch.
+// Both halves are non-empty if b has at least two colors.
+func (b qbox) split(ch int) (lo, hi qbox) {
+	sort.Slice(b, func(i, j int) bool {
+		return b[i].channel(ch) < b[j].channel(ch)
+	})
+	total := 0
+	for _, q := range b {
+		total += q.n
+	}
+	i, sum := 1, b[0].n
+	for i < len(b)-1 && sum*2 < total {
+		sum += b[i].n
+		i++
+	}
+	return b[:i:i], b[i:]
+}
+
+// average returns the pixel weighted average color of b.
+func (b qbox) average() color.NRGBA {
+	var r, g, bl, a, n int
+	for _, q := range b {
+		r += int(q.c.R) * q.n
+		g += int(q.c.G) * q.n
+		bl += int(q.c.B) * q.n
+		a += int(q.c.A) * q.n
+		n += q.n
+	}
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)}
+}
@@ -0,0 +1,90 @@
+// Package raster implements operations on raster map tiles.
+package raster
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	_ "golang.org/x/image/webp" // register WebP decoder
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// JPEGQuality is the default quality of JPEG tiles.
+var JPEGQuality = 90
+
+// ErrFormat is returned for tile formats that can't be encoded or decoded.
+var ErrFormat = errors.New("raster: unsupported tile format")
+
+// Decode decodes the PNG, JPEG, GIF or WebP tile data.
+func Decode(data []byte) (image.Image, mbtiles.TileFormat, error) {
+	f, _ := mbtiles.DetectFormat(data)
+	switch f {
+	case mbtiles.PNG, mbtiles.JPEG, mbtiles.GIF, mbtiles.WebP:
+	default:
+		return nil, f, ErrFormat
+	}
+	im, _, err := image.Decode(bytes.NewReader(data))
+	return im, f, err
+}
+
+// Encode encodes im in format f.
+// Encoding WebP tiles is not supported.
+func Encode(im image.Image, f mbtiles.TileFormat) ([]byte, error) {
+	return (&Encoder{Format: f}).Encode(im)
+}
+
+// Encoder encodes images as tiles.
+type Encoder struct {
+	// Format is the tile format, PNG, JPEG or GIF.
+	Format mbtiles.TileFormat
+
+	// Quality is the JPEG quality, JPEGQuality if zero.
+	Quality int
+
+	// Colors, if not zero, limits PNG tiles to a palette of Colors
+	// colors. GIF tiles always use a palette of at most 256 colors.
+	Colors int
+
+	// Compression is the PNG compression level.
+	Compression png.CompressionLevel
+}
+
+// CanEncode reports if tiles of format f can be encoded.
+func CanEncode(f mbtiles.TileFormat) bool {
+	return f == mbtiles.PNG || f == mbtiles.JPEG || f == mbtiles.GIF
+}
+
+// Encode encodes im in the format of e.
+func (e *Encoder) Encode(im image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch e.Format {
+	case mbtiles.PNG:
+		if e.Colors > 0 {
+			im = Quantize(im, e.Colors)
+		}
+		pe := png.Encoder{CompressionLevel: e.Compression}
+		err = pe.Encode(&buf, im)
+	case mbtiles.JPEG:
+		q := e.Quality
+		if q == 0 {
+			q = JPEGQuality
+		}
+		err = jpeg.Encode(&buf, im, &jpeg.Options{Quality: q})
+	case mbtiles.GIF:
+		n := e.Colors
+		if n <= 0 || n > 256 {
+			n = 256
+		}
+		err = gif.Encode(&buf, Quantize(im, n), nil)
+	default:
+		return nil, ErrFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,230 @@
+package raster
+
+import (
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"image"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// RecompressOptions control Recompress.
+type RecompressOptions struct {
+	// Encoder specifies the format of the re-encoded tiles.
+	Encoder
+
+	// Workers is the number of tiles encoded in parallel,
+	// GOMAXPROCS if zero.
+	Workers int
+
+	// Schema is the schema of the created MBTiles file.
+	Schema mbtiles.Schema
+}
+
+// RecompressReport summarizes the results of Recompress.
+type RecompressReport struct {
+	Tiles     int            `json:"tiles"`
+	Converted int            `json:"converted"` // re-encoded tiles
+	Larger    int            `json:"larger"`    // tiles kept because re-encoding made them larger
+	Skipped   int            `json:"skipped"`   // tiles kept because they are not raster tiles, or JPEG would lose their transparency
+	InBytes   int64          `json:"inbytes"`   // size of the source tiles
+	OutBytes  int64          `json:"outbytes"`  // size of the tiles written
+	Formats   map[string]int `json:"formats"`   // number of tiles by format in the new file
+}
+
+type recodejob struct {
+	z, x, y int
+	data    []byte
+	out     []byte
+	format  mbtiles.TileFormat
+	result  int // one of the recode constants
+	err     error
+}
+
+const (
+	recodeConverted = iota
+	recodeLarger
+	recodeSkipped
+)
+
+// Recompress creates the MBTiles file dst with the tiles of src re-encoded
+// in parallel with opt.Encoder. Tiles that would get larger are copied
+// unchanged, as are tiles that are not raster tiles. UTFGrids and metadata
+// are copied, the format metadata is set to the most common tile format.
+func Recompress(src *mbtiles.Map, dst string, opt *RecompressOptions) (*RecompressReport, error) {
+	if !CanEncode(opt.Format) {
+		return nil, ErrFormat
+	}
+	workers := opt.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	w, err := mbtiles.CreateSchema(dst, opt.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan *recodejob, workers)
+	results := make(chan *recodejob, workers)
+	done := make(chan struct{}) // closed to stop the pipeline on errors
+
+	var iterr error
+	go func() {
+		defer close(jobs)
+		it := src.Tiles(nil)
+		defer it.Close()
+		for it.Next() {
+			z, x, y, data := it.Tile()
+			j := &recodejob{z: z, x: x, y: y, data: append([]byte(nil), data...)}
+			select {
+			case jobs <- j:
+			case <-done:
+				return
+			}
+		}
+		iterr = it.Err()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				opt.recode(j)
+				select {
+				case results <- j:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	rep := &RecompressReport{Formats: make(map[string]int)}
+	grids := src.HasGrids()
+	stopped := false
+	for j := range results {
+		if err == nil {
+			err = j.err
+		}
+		if err == nil {
+			err = w.PutTile(j.z, j.x, j.y, j.out)
+		}
+		if err == nil && grids {
+			err = copyGrid(src, w, j.z, j.x, j.y)
+		}
+		if err != nil {
+			if !stopped {
+				close(done)
+				stopped = true
+			}
+			continue
+		}
+		rep.add(j)
+	}
+	if err == nil {
+		err = iterr
+	}
+	if err == nil {
+		err = w.WriteMetadata(src.Metadata())
+	}
+	if f := rep.format(); err == nil && f != "" {
+		err = w.SetMetadata("format", f)
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+	return rep, nil
+}
+
+// recode re-encodes the tile of j.
+func (e *Encoder) recode(j *recodejob) {
+	j.out, j.result = j.data, recodeSkipped
+	j.format, _ = mbtiles.DetectFormat(j.data)
+	switch j.format {
+	case mbtiles.PNG, mbtiles.JPEG, mbtiles.GIF, mbtiles.WebP:
+	default:
+		return
+	}
+	im, _, err := Decode(j.data)
+	if err != nil {
+		j.err = &mbtiles.TileError{Z: j.z, X: j.x, Y: j.y, Err: mbtiles.ErrCorrupt, Cause: err}
+		return
+	}
+	if e.Format == mbtiles.JPEG && !opaque(im) {
+		return
+	}
+	out, err := e.Encode(im)
+	if err != nil {
+		j.err = err
+		return
+	}
+	if len(out) >= len(j.data) {
+		j.result = recodeLarger
+		return
+	}
+	j.out, j.format, j.result = out, e.Format, recodeConverted
+}
+
+// opaque reports if im has no transparent pixels.
+func opaque(im image.Image) bool {
+	if o, ok := im.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := im.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := im.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func copyGrid(src *mbtiles.Map, w *mbtiles.Writer, z, x, y int) error {
+	g, err := src.GetGrid(z, x, y)
+	if err == nil {
+		err = w.WriteGrid(z, x, y, g)
+	}
+	if errors.Is(err, mbtiles.ErrTileNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (r *RecompressReport) add(j *recodejob) {
+	r.Tiles++
+	switch j.result {
+	case recodeConverted:
+		r.Converted++
+	case recodeLarger:
+		r.Larger++
+	default:
+		r.Skipped++
+	}
+	r.InBytes += int64(len(j.data))
+	r.OutBytes += int64(len(j.out))
+	r.Formats[j.format.String()]++
+}
+
+// format returns the most common known tile format.
+func (r *RecompressReport) format() string {
+	var f string
+	for k, n := range r.Formats {
+		if k != "" && (f == "" || n > r.Formats[f] || (n == r.Formats[f] && k < f)) {
+			f = k
+		}
+	}
+	return f
+}
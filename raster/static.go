@@ -0,0 +1,163 @@
+package raster
+
+import (
+	"context"
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"github.com/tajtiattila/go-mbtiles/tile"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"math"
+)
+
+// View is the area shown on a static map.
+type View struct {
+	Lon, Lat      float64 // center
+	Zoom          int
+	Width, Height int // size in pixels
+}
+
+// FitBounds returns the view of width x height pixels centered on b at
+// the highest zoom level up to maxz, at which b fits into the view.
+// Bounds with W > E cross the antimeridian.
+func FitBounds(b tile.Bounds, width, height, maxz int) View {
+	e := b.E
+	if b.W > e {
+		e += 360
+	}
+	x0, y0 := tile.LonLatToPixel(b.W, b.N, 0)
+	x1, y1 := tile.LonLatToPixel(e, b.S, 0)
+	z := maxz
+	for z > 0 && ((x1-x0)*float64(int(1)<<uint(z)) > float64(width) ||
+		(y1-y0)*float64(int(1)<<uint(z)) > float64(height)) {
+		z--
+	}
+	lon, lat := tile.PixelToLonLat((x0+x1)/2, (y0+y1)/2, 0)
+	if lon > 180 {
+		lon -= 360
+	}
+	return View{Lon: lon, Lat: lat, Zoom: z, Width: width, Height: height}
+}
+
+// Marker is a point marked on static maps.
+type Marker struct {
+	Lon, Lat float64
+	Color    color.Color // red if nil
+}
+
+// MarkerRadius is the radius of markers in pixels.
+var MarkerRadius = 6.0
+
+// RenderOptions control Render.
+type RenderOptions struct {
+	Markers []Marker
+	Overlay *Overlay // drawn below the markers
+}
+
+// Render returns the image of v stitched from the raster tiles of src,
+// with the overlay and markers of opt drawn on it. Missing tiles
+// are left transparent.
+func Render(ctx context.Context, src mbtiles.TileSource, v View, opt *RenderOptions) (*image.RGBA, error) {
+	if v.Width <= 0 || v.Height <= 0 || v.Zoom < 0 || v.Zoom > tile.MaxZoom {
+		return nil, errors.New("raster: invalid view")
+	}
+	cx, cy := tile.LonLatToPixel(v.Lon, v.Lat, v.Zoom)
+	ox := int(math.Floor(cx - float64(v.Width)/2))
+	oy := int(math.Floor(cy - float64(v.Height)/2))
+	dst := image.NewRGBA(image.Rect(0, 0, v.Width, v.Height))
+
+	n := 1 << uint(v.Zoom)
+	for ty := floordiv(oy, tile.Size); ty*tile.Size < oy+v.Height; ty++ {
+		if ty < 0 || ty >= n {
+			continue
+		}
+		for tx := floordiv(ox, tile.Size); tx*tile.Size < ox+v.Width; tx++ {
+			wx := (tx%n + n) % n // wrap around the antimeridian
+			data, err := mbtiles.GetTileContext(ctx, src, v.Zoom, wx, tile.FlipY(v.Zoom, ty))
+			if errors.Is(err, mbtiles.ErrTileNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			im, _, err := Decode(data)
+			if err != nil {
+				return nil, &mbtiles.TileError{Z: v.Zoom, X: wx, Y: tile.FlipY(v.Zoom, ty), Err: mbtiles.ErrCorrupt, Cause: err}
+			}
+			r := image.Rect(0, 0, tile.Size, tile.Size).Add(image.Pt(tx*tile.Size-ox, ty*tile.Size-oy))
+			if b := im.Bounds(); b.Dx() != tile.Size || b.Dy() != tile.Size {
+				draw.BiLinear.Scale(dst, r, im, b, draw.Src, nil)
+			} else {
+				draw.Draw(dst, r, im, b.Min, draw.Src)
+			}
+		}
+	}
+
+	if opt == nil {
+		return dst, nil
+	}
+	// proj returns the image position of lon, lat on the copy of the
+	// world nearest to x = ref, so that shapes wrap around the
+	// antimeridian like the tiles
+	world := float64(int64(tile.Size) << uint(v.Zoom))
+	proj := func(lon, lat, ref float64) point {
+		px, py := tile.LonLatToPixel(lon, lat, v.Zoom)
+		x := px - float64(ox)
+		return point{x - world*math.Floor((x-ref)/world+0.5), py - float64(oy)}
+	}
+	center := cx - float64(ox)
+	p := newPainter(dst)
+	if opt.Overlay != nil {
+		opt.Overlay.draw(p, proj, center)
+	}
+	for _, m := range opt.Markers {
+		c := m.Color
+		if c == nil {
+			c = color.NRGBA{0xe0, 0x20, 0x20, 0xff}
+		}
+		drawMarker(p, proj(m.Lon, m.Lat, center), c)
+	}
+	return dst, nil
+}
+
+func drawMarker(p *painter, at point, c color.Color) {
+	p.disc(at, MarkerRadius+1.5, color.White)
+	p.disc(at, MarkerRadius, c)
+}
+
+// draw paints the shapes of o. The first point of each shape is projected
+// nearest to x = center, the rest nearest to the previous point to keep
+// shapes crossing the antimeridian in one piece.
+func (o *Overlay) draw(p *painter, proj func(lon, lat, ref float64) point, center float64) {
+	for _, s := range o.shapes {
+		ref := center
+		paths := make([][]point, len(s.paths))
+		for i, path := range s.paths {
+			paths[i] = make([]point, len(path))
+			for j, c := range path {
+				paths[i][j] = proj(c[0], c[1], ref)
+				ref = paths[i][j].x
+			}
+		}
+		switch s.kind {
+		case shapePoint:
+			drawMarker(p, paths[0][0], s.style.marker)
+		case shapeLine:
+			p.stroke(paths[0], s.style.width, false, s.style.stroke)
+		case shapePolygon:
+			p.fill(paths, s.style.fill)
+			for _, ring := range paths {
+				p.stroke(ring, s.style.width, true, s.style.stroke)
+			}
+		}
+	}
+}
+
+func floordiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
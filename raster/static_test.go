@@ -0,0 +1,50 @@
+package raster
+
+import (
+	"context"
+	"image/color"
+	"testing"
+)
+
+func TestRenderAntimeridian(t *testing.T) {
+	src := &memsource{}
+	overlay, err := ParseGeoJSON([]byte(`{"type":"LineString","coordinates":[[170,-30],[-170,-30]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one degree is 1024/360 pixels at zoom level 2
+	v := View{Lon: 179, Lat: 0, Zoom: 2, Width: 200, Height: 200}
+	opt := &RenderOptions{
+		Markers: []Marker{{Lon: -175, Lat: 0, Color: color.NRGBA{0, 0, 255, 255}}},
+		Overlay: overlay,
+	}
+	im, err := Render(context.Background(), src, v, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(x, y int) color.NRGBA {
+		return color.NRGBAModel.Convert(im.At(x, y)).(color.NRGBA)
+	}
+	if c := at(117, 100); c != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("marker east of the antimeridian: got %v", c)
+	}
+	// the line at -30° is about 90 pixels south of the center
+	y := 198
+	for y > 100 && at(100, y).A == 0 {
+		y--
+	}
+	if y <= 100 {
+		t.Fatal("line across the antimeridian not drawn")
+	}
+	// from x = 75 to 131
+	for _, x := range []int{80, 125} {
+		if c := at(x, y); c.A == 0 {
+			t.Errorf("line at x = %d: got %v", x, c)
+		}
+	}
+	for _, x := range []int{10, 60, 145, 190} {
+		if c := at(x, y); c.A != 0 {
+			t.Errorf("line beyond its ends at x = %d: got %v", x, c)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package raster
+
+import (
+	"errors"
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+)
+
+// Filters are the resampling filters by name.
+var Filters = map[string]draw.Interpolator{
+	"nearest":        draw.NearestNeighbor,
+	"approxbilinear": draw.ApproxBiLinear,
+	"bilinear":       draw.BiLinear,
+	"catmullrom":     draw.CatmullRom,
+}
+
+// UnderzoomOptions control Underzoom.
+type UnderzoomOptions struct {
+	// MinZoom is the lowest zoom level created.
+	MinZoom int
+
+	// Format is the format of the created tiles. The format of
+	// the existing tiles is used if it is UnknownFormat.
+	// WebP encoding is not supported.
+	Format mbtiles.TileFormat
+
+	// Filter is used to downsample tiles, draw.BiLinear if nil.
+	Filter draw.Interpolator
+
+	// Background fills the transparent parts of JPEG tiles,
+	// white if nil.
+	Background color.Color
+}
+
+// Underzoom creates the zoom levels from the lowest zoom level in the
+// MBTiles file fn down to opt.MinZoom. Each new tile is made by
+// downsampling its four children, missing children are left transparent,
+// or filled with opt.Background for JPEG.
+// The minzoom metadata is updated, and the number of created tiles
+// is returned. Only raster tilesets are supported.
+func Underzoom(fn string, opt *UnderzoomOptions) (n int, err error) {
+	if opt == nil {
+		opt = new(UnderzoomOptions)
+	}
+	filter := opt.Filter
+	if filter == nil {
+		filter = draw.BiLinear
+	}
+	mbt, err := mbtiles.Open(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer mbt.Close()
+
+	minz, format, err := lowestZoom(mbt)
+	if err != nil || minz <= opt.MinZoom {
+		return 0, err
+	}
+	if opt.Format != mbtiles.UnknownFormat {
+		format = opt.Format
+	}
+	if format == mbtiles.WebP {
+		return 0, errors.New("raster: WebP encoding is not supported")
+	}
+
+	w, err := mbtiles.OpenWriter(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	for z := minz - 1; z >= opt.MinZoom; z-- {
+		parents, err := parentTiles(mbt, z+1)
+		if err != nil {
+			return n, err
+		}
+		for _, p := range parents {
+			im, err := mergeChildren(mbt, p[0], p[1], p[2], filter)
+			if err != nil {
+				return n, err
+			}
+			if format == mbtiles.JPEG && !opaque(im) {
+				im = flatten(im, opt.Background)
+			}
+			data, err := Encode(im, format)
+			if err != nil {
+				return n, err
+			}
+			if err = w.PutTile(p[0], p[1], p[2], data); err != nil {
+				return n, err
+			}
+			n++
+		}
+		// make the new tiles visible to mbt
+		if err = w.Flush(); err != nil {
+			return n, err
+		}
+	}
+	return n, w.SetMetadata("minzoom", strconv.Itoa(opt.MinZoom))
+}
+
+// lowestZoom returns the lowest zoom level in mbt, and the format of its tiles.
+func lowestZoom(mbt *mbtiles.Map) (int, mbtiles.TileFormat, error) {
+	it := mbt.Tiles(nil)
+	defer it.Close()
+	if !it.Next() {
+		if err := it.Err(); err != nil {
+			return 0, 0, err
+		}
+		return 0, 0, errors.New("raster: tileset is empty")
+	}
+	z, _, _, data := it.Tile()
+	f, _ := mbtiles.DetectFormat(data)
+	switch f {
+	case mbtiles.PNG, mbtiles.JPEG, mbtiles.GIF, mbtiles.WebP:
+	default:
+		return 0, 0, ErrFormat
+	}
+	return z, f, nil
+}
+
+// parentTiles returns the parents of the tiles at zoom level z
+// in TMS coordinates.
+func parentTiles(mbt *mbtiles.Map, z int) ([][3]int, error) {
+	seen := make(map[[3]int]bool)
+	it := mbt.Tiles(&mbtiles.TileFilter{MinZoom: z, MaxZoom: z, HasMaxZoom: true})
+	defer it.Close()
+	for it.Next() {
+		_, x, y, _ := it.Tile()
+		seen[[3]int{z - 1, x >> 1, y >> 1}] = true
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	v := make([][3]int, 0, len(seen))
+	for p := range seen {
+		v = append(v, p)
+	}
+	sort.Slice(v, func(i, j int) bool {
+		if v[i][2] != v[j][2] {
+			return v[i][2] < v[j][2]
+		}
+		return v[i][1] < v[j][1]
+	})
+	return v, nil
+}
+
+// mergeChildren returns the tile z, x, y in TMS coordinates
+// downsampled from its children.
+func mergeChildren(mbt *mbtiles.Map, z, x, y int, filter draw.Interpolator) (image.Image, error) {
+	var dst *image.RGBA
+	size := 0
+	for i := 0; i < 4; i++ {
+		dx, dy := i&1, i>>1
+		data, err := mbt.GetTile(z+1, 2*x+dx, 2*y+dy)
+		if errors.Is(err, mbtiles.ErrTileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		im, _, err := Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		b := im.Bounds()
+		if dst == nil {
+			size = b.Dx()
+			dst = image.NewRGBA(image.Rect(0, 0, 2*size, 2*size))
+		}
+		// TMS rows grow to the north
+		r := image.Rect(0, 0, size, size).Add(image.Pt(dx*size, (1-dy)*size))
+		draw.Draw(dst, r, im, b.Min, draw.Src)
+	}
+	if dst == nil {
+		return nil, mbtiles.ErrTileNotFound
+	}
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	filter.Scale(out, out.Bounds(), dst, dst.Bounds(), draw.Src, nil)
+	return out, nil
+}
+
+// flatten returns im drawn over the background bg, white if nil.
+func flatten(im image.Image, bg color.Color) image.Image {
+	if bg == nil {
+		bg = color.White
+	}
+	b := im.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, b, im, b.Min, draw.Over)
+	return dst
+}
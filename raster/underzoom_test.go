@@ -0,0 +1,72 @@
+package raster
+
+import (
+	"github.com/tajtiattila/go-mbtiles/mbtiles"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnderzoomJPEG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		bg   color.Color
+		want color.NRGBA
+	}{
+		{nil, color.NRGBA{255, 255, 255, 255}},
+		{color.NRGBA{0, 128, 0, 255}, color.NRGBA{0, 128, 0, 255}},
+	} {
+		fn := filepath.Join(dir, "jpeg.mbtiles")
+		os.Remove(fn)
+		w, err := mbtiles.Create(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// top left child of tile 0/0/0 only
+		if err = w.PutTile(1, 0, 1, solid(t, mbtiles.JPEG, 16, red)); err != nil {
+			t.Fatal(err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := Underzoom(fn, &UnderzoomOptions{Background: tt.bg})
+		if err != nil || n != 1 {
+			t.Fatalf("Underzoom: got %d, %v", n, err)
+		}
+		mbt, err := mbtiles.Open(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := mbt.GetTile(0, 0, 0)
+		mbt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, f, err := Decode(data)
+		if err != nil || f != mbtiles.JPEG {
+			t.Fatalf("got %v, %v, want JPEG", f, err)
+		}
+		at := func(x, y int) color.NRGBA {
+			return color.NRGBAModel.Convert(im.At(x, y)).(color.NRGBA)
+		}
+		if c := at(4, 4); c.R < 230 || c.G > 25 || c.B > 25 {
+			t.Errorf("child: got %v, want red", c)
+		}
+		// JPEG is lossy
+		d := func(u, v uint8) bool { return int(u) <= int(v)+10 && int(v) <= int(u)+10 }
+		for _, p := range [][2]int{{12, 4}, {4, 12}, {12, 12}} {
+			c := at(p[0], p[1])
+			if !d(c.R, tt.want.R) || !d(c.G, tt.want.G) || !d(c.B, tt.want.B) {
+				t.Errorf("missing child at %v: got %v, want %v", p, c, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tile
+
+import (
+	"math"
+)
+
+// Range returns the XYZ tile range covering b at zoom level z.
+// Bounds crossing the antimeridian (W > E) are not supported,
+// use Cover for them.
+func Range(b Bounds, z int) (x0, y0, x1, y1 int) {
+	t0 := FromLonLat(b.W, b.N, z)
+	t1 := FromLonLat(b.E, b.S, z)
+	return t0.X, t0.Y, t1.X, t1.Y
+}
+
+// RangeBounds returns the lon/lat bounds of
+// the XYZ tile range x0, y0 - x1, y1 at zoom level z.
+func RangeBounds(z, x0, y0, x1, y1 int) Bounds {
+	b0 := Tile{z, x0, y0}.Bounds()
+	b1 := Tile{z, x1, y1}.Bounds()
+	return Bounds{W: b0.W, S: b1.S, E: b1.E, N: b0.N}
+}
+
+// Cover returns the tiles intersecting b at zoom level z
+// in row major order. Bounds with W > E cross the antimeridian.
+func Cover(b Bounds, z int) []Tile {
+	if b.W > b.E {
+		w := Cover(Bounds{W: b.W, S: b.S, E: 180, N: b.N}, z)
+		e := Cover(Bounds{W: -180, S: b.S, E: b.E, N: b.N}, z)
+		return append(w, e...)
+	}
+	x0, y0, x1, y1 := Range(b, z)
+	v := make([]Tile, 0, (x1-x0+1)*(y1-y0+1))
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			v = append(v, Tile{z, x, y})
+		}
+	}
+	return v
+}
+
+// CoverPolygon returns the tiles intersecting the polygon at zoom level z
+// in row major order. The first ring of the polygon is its outer boundary,
+// the rest are holes. Each ring is a list of lon/lat points, closing
+// the ring by repeating its first point is optional.
+func CoverPolygon(rings [][][2]float64, z int) []Tile {
+	if len(rings) == 0 || len(rings[0]) == 0 {
+		return nil
+	}
+	// rings in tile units
+	trings := make([][][2]float64, len(rings))
+	b := Bounds{W: math.Inf(1), S: math.Inf(1), E: math.Inf(-1), N: math.Inf(-1)}
+	for i, ring := range rings {
+		tr := make([][2]float64, len(ring))
+		for j, p := range ring {
+			if i == 0 {
+				b.W, b.E = math.Min(b.W, p[0]), math.Max(b.E, p[0])
+				b.S, b.N = math.Min(b.S, p[1]), math.Max(b.N, p[1])
+			}
+			px, py := LonLatToPixel(p[0], p[1], z)
+			tr[j] = [2]float64{px / Size, py / Size}
+		}
+		trings[i] = tr
+	}
+
+	x0, y0, x1, y1 := Range(b, z)
+	var v []Tile
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if intersects(trings, float64(x), float64(y), float64(x+1), float64(y+1)) {
+				v = append(v, Tile{z, x, y})
+			}
+		}
+	}
+	return v
+}
+
+// intersects reports if the polygon intersects the rectangle x0, y0 - x1, y1.
+func intersects(rings [][][2]float64, x0, y0, x1, y1 float64) bool {
+	for _, ring := range rings {
+		for i := range ring {
+			p, q := ring[i], ring[(i+1)%len(ring)]
+			if clipSegment(p, q, x0, y0, x1, y1) {
+				return true
+			}
+		}
+	}
+	// no edges cross the rectangle, it is either
+	// completely inside or outside the polygon
+	return contains(rings, (x0+x1)/2, (y0+y1)/2)
+}
+
+// clipSegment reports if the segment p-q intersects the
+// rectangle x0, y0 - x1, y1 using the Liang-Barsky algorithm.
+func clipSegment(p, q [2]float64, x0, y0, x1, y1 float64) bool {
+	dx, dy := q[0]-p[0], q[1]-p[1]
+	t0, t1 := 0.0, 1.0
+	for _, c := range [4][2]float64{
+		{-dx, p[0] - x0},
+		{dx, x1 - p[0]},
+		{-dy, p[1] - y0},
+		{dy, y1 - p[1]},
+	} {
+		pv, qv := c[0], c[1]
+		if pv == 0 {
+			if qv < 0 {
+				return false
+			}
+			continue
+		}
+		r := qv / pv
+		if pv < 0 {
+			if r > t1 {
+				return false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	return t0 <= t1
+}
+
+// contains reports if x, y is inside the polygon using the even-odd rule.
+func contains(rings [][][2]float64, x, y float64) bool {
+	in := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			p, q := ring[i], ring[j]
+			if (p[1] > y) != (q[1] > y) && x < (q[0]-p[0])*(y-p[1])/(q[1]-p[1])+p[0] {
+				in = !in
+			}
+		}
+	}
+	return in
+}
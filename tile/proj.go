@@ -0,0 +1,85 @@
+package tile
+
+import (
+	"math"
+)
+
+// MaxLat is the highest latitude covered by Web Mercator tiles.
+const MaxLat = 85.0511287798066
+
+// EarthRadius is the radius used by the Web Mercator projection in meters.
+const EarthRadius = 6378137
+
+// OriginShift is the distance of the map edges from the origin in meters.
+const OriginShift = math.Pi * EarthRadius
+
+// Bounds is a lon/lat bounding box.
+type Bounds struct {
+	W, S, E, N float64
+}
+
+// World are the bounds of the Web Mercator projection.
+var World = Bounds{W: -180, S: -MaxLat, E: 180, N: MaxLat}
+
+func clampLat(lat float64) float64 {
+	return math.Max(-MaxLat, math.Min(MaxLat, lat))
+}
+
+// LonLatToPixel returns the global pixel coordinates of lon, lat at
+// zoom level z, counted from the north west corner of the map.
+// Latitudes are clamped to the Web Mercator range.
+func LonLatToPixel(lon, lat float64, z int) (px, py float64) {
+	size := mapSize(z)
+	prj := math.Log(math.Tan(math.Pi/4 + clampLat(lat)*math.Pi/360))
+	return (lon + 180) / 360 * size, (1 - prj/math.Pi) / 2 * size
+}
+
+// PixelToLonLat returns the lon/lat coordinates of
+// the global pixel coordinates px, py at zoom level z.
+func PixelToLonLat(px, py float64, z int) (lon, lat float64) {
+	size := mapSize(z)
+	lon = px/size*360 - 180
+	lat = math.Atan(math.Sinh(math.Pi*(1-2*py/size))) * 180 / math.Pi
+	return lon, lat
+}
+
+// LonLatToMeters returns the Web Mercator (EPSG:3857) coordinates of lon, lat.
+// Latitudes are clamped to the Web Mercator range.
+func LonLatToMeters(lon, lat float64) (mx, my float64) {
+	mx = lon * OriginShift / 180
+	my = math.Log(math.Tan(math.Pi/4+clampLat(lat)*math.Pi/360)) * EarthRadius
+	return mx, my
+}
+
+// MetersToLonLat returns the lon/lat coordinates of
+// the Web Mercator coordinates mx, my.
+func MetersToLonLat(mx, my float64) (lon, lat float64) {
+	lon = mx / OriginShift * 180
+	lat = (2*math.Atan(math.Exp(my/EarthRadius)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// MetersToPixel returns the global pixel coordinates of
+// the Web Mercator coordinates mx, my at zoom level z.
+func MetersToPixel(mx, my float64, z int) (px, py float64) {
+	size := mapSize(z)
+	return (mx + OriginShift) / (2 * OriginShift) * size, (OriginShift - my) / (2 * OriginShift) * size
+}
+
+// PixelToMeters returns the Web Mercator coordinates of
+// the global pixel coordinates px, py at zoom level z.
+func PixelToMeters(px, py float64, z int) (mx, my float64) {
+	size := mapSize(z)
+	return px/size*2*OriginShift - OriginShift, OriginShift - py/size*2*OriginShift
+}
+
+// Resolution returns the size of a pixel in meters
+// at the equator at zoom level z.
+func Resolution(z int) float64 {
+	return 2 * OriginShift / mapSize(z)
+}
+
+// mapSize returns the size of the map in pixels at zoom level z.
+func mapSize(z int) float64 {
+	return float64(int64(Size) << uint(z))
+}
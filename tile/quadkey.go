@@ -0,0 +1,42 @@
+package tile
+
+import (
+	"errors"
+)
+
+var errQuadkey = errors.New("invalid quadkey")
+
+// Quadkey returns the Bing Maps quadkey of t.
+// The quadkey of the zoom level 0 tile is the empty string.
+func (t Tile) Quadkey() string {
+	b := make([]byte, t.Z)
+	for i := 0; i < t.Z; i++ {
+		mask := 1 << uint(t.Z-1-i)
+		d := byte('0')
+		if t.X&mask != 0 {
+			d++
+		}
+		if t.Y&mask != 0 {
+			d += 2
+		}
+		b[i] = d
+	}
+	return string(b)
+}
+
+// ParseQuadkey returns the tile of quadkey s.
+func ParseQuadkey(s string) (Tile, error) {
+	if len(s) > MaxZoom {
+		return Tile{}, errQuadkey
+	}
+	t := Tile{Z: len(s)}
+	for i := 0; i < len(s); i++ {
+		d := s[i] - '0'
+		if d > 3 {
+			return Tile{}, errQuadkey
+		}
+		t.X = t.X<<1 | int(d&1)
+		t.Y = t.Y<<1 | int(d>>1)
+	}
+	return t, nil
+}
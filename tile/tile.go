@@ -0,0 +1,111 @@
+// Package tile implements coordinate calculations for
+// Web Mercator map tiles.
+//
+// Tiles use XYZ coordinates, rows counted from the north.
+// MBTiles files use TMS rows counted from the south, FlipY
+// converts between the two.
+package tile
+
+import (
+	"fmt"
+)
+
+// Size is the size of tiles in pixels.
+const Size = 256
+
+// MaxZoom is the highest zoom level supported.
+const MaxZoom = 30
+
+// Tile is a tile in XYZ coordinates.
+type Tile struct {
+	Z, X, Y int
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Valid reports if t has valid coordinates.
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > MaxZoom {
+		return false
+	}
+	n := 1 << uint(t.Z)
+	return t.X >= 0 && t.Y >= 0 && t.X < n && t.Y < n
+}
+
+// FlipY converts row y at zoom level z between XYZ and TMS.
+func FlipY(z, y int) int {
+	return (1 << uint(z)) - 1 - y
+}
+
+// Flip returns t with its row converted between XYZ and TMS.
+func (t Tile) Flip() Tile {
+	return Tile{t.Z, t.X, FlipY(t.Z, t.Y)}
+}
+
+// Parent returns the tile at zoom level t.Z-1 containing t.
+// The parent of the zoom level 0 tile is itself.
+func (t Tile) Parent() Tile {
+	if t.Z == 0 {
+		return t
+	}
+	return Tile{t.Z - 1, t.X >> 1, t.Y >> 1}
+}
+
+// Ancestor returns the tile at zoom level z <= t.Z containing t.
+func (t Tile) Ancestor(z int) Tile {
+	if z >= t.Z {
+		return t
+	}
+	if z < 0 {
+		z = 0
+	}
+	d := uint(t.Z - z)
+	return Tile{z, t.X >> d, t.Y >> d}
+}
+
+// Children returns the four tiles at zoom level t.Z+1 within t,
+// in top left, top right, bottom left, bottom right order.
+func (t Tile) Children() [4]Tile {
+	z, x, y := t.Z+1, t.X*2, t.Y*2
+	return [4]Tile{{z, x, y}, {z, x + 1, y}, {z, x, y + 1}, {z, x + 1, y + 1}}
+}
+
+// Bounds returns the lon/lat bounds of t.
+func (t Tile) Bounds() Bounds {
+	w, n := PixelToLonLat(float64(t.X*Size), float64(t.Y*Size), t.Z)
+	e, s := PixelToLonLat(float64((t.X+1)*Size), float64((t.Y+1)*Size), t.Z)
+	return Bounds{W: w, S: s, E: e, N: n}
+}
+
+// Center returns the lon/lat coordinates of the center of t.
+func (t Tile) Center() (lon, lat float64) {
+	return PixelToLonLat((float64(t.X)+0.5)*Size, (float64(t.Y)+0.5)*Size, t.Z)
+}
+
+// FromLonLat returns the tile containing lon, lat at zoom level z.
+// Coordinates outside the Web Mercator range are clamped.
+func FromLonLat(lon, lat float64, z int) Tile {
+	t, _, _ := PixelFromLonLat(lon, lat, z)
+	return t
+}
+
+// PixelFromLonLat returns the tile containing lon, lat at zoom level z
+// like FromLonLat, and the pixel px, py of lon, lat within the tile.
+func PixelFromLonLat(lon, lat float64, z int) (t Tile, px, py int) {
+	fx, fy := LonLatToPixel(lon, lat, z)
+	last := Size<<uint(z) - 1
+	px, py = clamp(int(fx), 0, last), clamp(int(fy), 0, last)
+	return Tile{z, px / Size, py / Size}, px % Size, py % Size
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
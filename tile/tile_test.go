@@ -0,0 +1,287 @@
+package tile
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestLonLatToPixel(t *testing.T) {
+	tests := []struct {
+		lon, lat float64
+		z        int
+		px, py   float64
+	}{
+		{0, 0, 0, 128, 128},
+		{0, 0, 1, 256, 256},
+		{-180, MaxLat, 0, 0, 0},
+		{180, -MaxLat, 0, 256, 256},
+		{-180, 0, 2, 0, 512},
+		{180, 0, 2, 1024, 512},
+		{90, 0, 1, 384, 256},
+
+		// latitudes are clamped
+		{0, 85.06, 0, 128, 0},
+		{0, 90, 3, 1024, 0},
+		{0, -90, 3, 1024, 2048},
+	}
+	for _, tt := range tests {
+		px, py := LonLatToPixel(tt.lon, tt.lat, tt.z)
+		if !near(px, tt.px) || !near(py, tt.py) {
+			t.Errorf("LonLatToPixel(%v, %v, %d) = %v, %v, want %v, %v",
+				tt.lon, tt.lat, tt.z, px, py, tt.px, tt.py)
+		}
+		if math.Abs(tt.lat) > MaxLat {
+			continue
+		}
+		lon, lat := PixelToLonLat(px, py, tt.z)
+		if !near(lon, tt.lon) || !near(lat, tt.lat) {
+			t.Errorf("PixelToLonLat(%v, %v, %d) = %v, %v, want %v, %v",
+				px, py, tt.z, lon, lat, tt.lon, tt.lat)
+		}
+	}
+}
+
+func TestMeters(t *testing.T) {
+	tests := []struct {
+		lon, lat float64
+		mx, my   float64
+	}{
+		{0, 0, 0, 0},
+		{180, 0, OriginShift, 0},
+		{-180, MaxLat, -OriginShift, OriginShift},
+		{180, -90, OriginShift, -OriginShift},
+	}
+	for _, tt := range tests {
+		mx, my := LonLatToMeters(tt.lon, tt.lat)
+		if math.Abs(mx-tt.mx) > 1e-3 || math.Abs(my-tt.my) > 1e-3 {
+			t.Errorf("LonLatToMeters(%v, %v) = %v, %v, want %v, %v",
+				tt.lon, tt.lat, mx, my, tt.mx, tt.my)
+		}
+		px, py := MetersToPixel(mx, my, 4)
+		wx, wy := LonLatToPixel(tt.lon, tt.lat, 4)
+		if !near(px, wx) || !near(py, wy) {
+			t.Errorf("MetersToPixel(%v, %v, 4) = %v, %v, want %v, %v", mx, my, px, py, wx, wy)
+		}
+		if qx, qy := PixelToMeters(px, py, 4); math.Abs(qx-mx) > 1e-3 || math.Abs(qy-my) > 1e-3 {
+			t.Errorf("PixelToMeters(%v, %v, 4) = %v, %v, want %v, %v", px, py, qx, qy, mx, my)
+		}
+	}
+}
+
+func TestFromLonLat(t *testing.T) {
+	tests := []struct {
+		lon, lat float64
+		z        int
+		want     Tile
+	}{
+		{0, 0, 0, Tile{0, 0, 0}},
+		{10, 10, 2, Tile{2, 2, 1}},
+		{-10, -10, 2, Tile{2, 1, 2}},
+		{19.04, 47.5, 10, Tile{10, 566, 358}},
+
+		// edges of the map and the antimeridian
+		{-180, MaxLat, 3, Tile{3, 0, 0}},
+		{180, -MaxLat, 3, Tile{3, 7, 7}},
+		{179.999, 0, 1, Tile{1, 1, 1}},
+		{-179.999, 0, 1, Tile{1, 0, 1}},
+
+		// clamped
+		{0, 89, 2, Tile{2, 2, 0}},
+		{0, -89, 2, Tile{2, 2, 3}},
+		{-200, 0, 2, Tile{2, 0, 2}},
+		{200, 0, 2, Tile{2, 3, 2}},
+	}
+	for _, tt := range tests {
+		if got := FromLonLat(tt.lon, tt.lat, tt.z); got != tt.want {
+			t.Errorf("FromLonLat(%v, %v, %d) = %v, want %v", tt.lon, tt.lat, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestPixelFromLonLat(t *testing.T) {
+	tests := []struct {
+		lon, lat float64
+		z        int
+		want     Tile
+		px, py   int
+	}{
+		{0, 0, 0, Tile{0, 0, 0}, 128, 128},
+		{0, 0, 1, Tile{1, 1, 1}, 0, 0},
+		{-0.001, 0.001, 1, Tile{1, 0, 0}, 255, 255},
+		{-180, MaxLat, 3, Tile{3, 0, 0}, 0, 0},
+		{180, -MaxLat, 3, Tile{3, 7, 7}, 255, 255},
+		{19.04, 47.5, 10, Tile{10, 566, 358}, 40, 18},
+		{200, 89, 2, Tile{2, 3, 0}, 255, 0},
+	}
+	for _, tt := range tests {
+		got, px, py := PixelFromLonLat(tt.lon, tt.lat, tt.z)
+		if got != tt.want || px != tt.px || py != tt.py {
+			t.Errorf("PixelFromLonLat(%v, %v, %d) = %v, %d, %d, want %v, %d, %d",
+				tt.lon, tt.lat, tt.z, got, px, py, tt.want, tt.px, tt.py)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		t    Tile
+		want Bounds
+	}{
+		{Tile{0, 0, 0}, World},
+		{Tile{1, 0, 0}, Bounds{W: -180, S: 0, E: 0, N: MaxLat}},
+		{Tile{1, 1, 1}, Bounds{W: 0, S: -MaxLat, E: 180, N: 0}},
+		{Tile{2, 3, 0}, Bounds{W: 90, S: 66.51326044311186, E: 180, N: MaxLat}},
+	}
+	for _, tt := range tests {
+		b := tt.t.Bounds()
+		if !near(b.W, tt.want.W) || !near(b.S, tt.want.S) || !near(b.E, tt.want.E) || !near(b.N, tt.want.N) {
+			t.Errorf("%v.Bounds() = %+v, want %+v", tt.t, b, tt.want)
+		}
+	}
+}
+
+func TestFlipY(t *testing.T) {
+	tests := []struct {
+		z, y, want int
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{1, 1, 0},
+		{10, 356, 667},
+		{31, 0, 1<<31 - 1},
+		{31, 1<<31 - 1, 0},
+	}
+	for _, tt := range tests {
+		if got := FlipY(tt.z, tt.y); got != tt.want {
+			t.Errorf("FlipY(%d, %d) = %d, want %d", tt.z, tt.y, got, tt.want)
+		}
+		if got := FlipY(tt.z, tt.want); got != tt.y {
+			t.Errorf("FlipY(%d, %d) = %d, want %d", tt.z, tt.want, got, tt.y)
+		}
+	}
+}
+
+func TestQuadkey(t *testing.T) {
+	tests := []struct {
+		t  Tile
+		qk string
+	}{
+		{Tile{0, 0, 0}, ""},
+		{Tile{1, 1, 0}, "1"},
+		{Tile{1, 0, 1}, "2"},
+		{Tile{3, 3, 5}, "213"},
+		{Tile{MaxZoom, 1<<MaxZoom - 1, 0}, strings.Repeat("1", MaxZoom)},
+		{Tile{MaxZoom, 1<<MaxZoom - 1, 1<<MaxZoom - 1}, strings.Repeat("3", MaxZoom)},
+	}
+	for _, tt := range tests {
+		if got := tt.t.Quadkey(); got != tt.qk {
+			t.Errorf("%v.Quadkey() = %q, want %q", tt.t, got, tt.qk)
+		}
+		got, err := ParseQuadkey(tt.qk)
+		if err != nil || got != tt.t {
+			t.Errorf("ParseQuadkey(%q) = %v, %v, want %v", tt.qk, got, err, tt.t)
+		}
+	}
+}
+
+func TestParseQuadkeyInvalid(t *testing.T) {
+	for _, qk := range []string{
+		"4",
+		"0124",
+		"a",
+		"12-",
+		"1 2",
+		strings.Repeat("0", MaxZoom+1), // zoom 31
+		strings.Repeat("2", 40),
+	} {
+		if got, err := ParseQuadkey(qk); err == nil {
+			t.Errorf("ParseQuadkey(%q) = %v, want error", qk, got)
+		}
+	}
+}
+
+// allExcept returns the tiles at zoom level z
+// in row major order, except the ones in skip.
+func allExcept(z int, skip ...Tile) []Tile {
+	var v []Tile
+	n := 1 << uint(z)
+loop:
+	for i := 0; i < n*n; i++ {
+		t := Tile{z, i % n, i / n}
+		for _, s := range skip {
+			if t == s {
+				continue loop
+			}
+		}
+		v = append(v, t)
+	}
+	return v
+}
+
+func TestCover(t *testing.T) {
+	tests := []struct {
+		name string
+		b    Bounds
+		z    int
+		want []Tile
+	}{
+		{"point", Bounds{W: 10, S: 10, E: 10, N: 10}, 2, []Tile{{2, 2, 1}}},
+		{"box", Bounds{W: -100, S: -10, E: 10, N: 10}, 2, []Tile{
+			{2, 0, 1}, {2, 1, 1}, {2, 2, 1},
+			{2, 0, 2}, {2, 1, 2}, {2, 2, 2},
+		}},
+		{"world", World, 1, allExcept(1)},
+		{"antimeridian", Bounds{W: 170, S: -10, E: -170, N: 10}, 2, []Tile{
+			{2, 3, 1}, {2, 3, 2},
+			{2, 0, 1}, {2, 0, 2},
+		}},
+	}
+	for _, tt := range tests {
+		if got := Cover(tt.b, tt.z); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Cover(%+v, %d) = %v, want %v", tt.name, tt.b, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestCoverPolygon(t *testing.T) {
+	tests := []struct {
+		name  string
+		rings [][][2]float64
+		z     int
+		want  []Tile
+	}{
+		{"empty", nil, 2, nil},
+		{"empty ring", [][][2]float64{{}}, 2, nil},
+		{"point", [][][2]float64{{{10, 10}}}, 2, []Tile{{2, 2, 1}}},
+		{"degenerate", [][][2]float64{{{10, 10}, {10, 10}, {10, 10}}}, 3, []Tile{{3, 4, 3}}},
+
+		// a line from row 1 to row 2, crossing the
+		// row boundary at the equator in column 2
+		{"line", [][][2]float64{{{-100, 20}, {100, -10}}}, 2, []Tile{
+			{2, 0, 1}, {2, 1, 1}, {2, 2, 1},
+			{2, 2, 2}, {2, 3, 2},
+		}},
+
+		{"triangle", [][][2]float64{{{-170, 80}, {170, 80}, {-170, -70}}}, 1, []Tile{
+			{1, 0, 0}, {1, 1, 0},
+			{1, 0, 1},
+		}},
+
+		// the hole contains columns and rows 3 and 4 entirely
+		{"hole", [][][2]float64{
+			{{-170, -80}, {170, -80}, {170, 80}, {-170, 80}},
+			{{-80, -60}, {-80, 60}, {80, 60}, {80, -60}},
+		}, 3, allExcept(3, Tile{3, 3, 3}, Tile{3, 4, 3}, Tile{3, 3, 4}, Tile{3, 4, 4})},
+	}
+	for _, tt := range tests {
+		if got := CoverPolygon(tt.rings, tt.z); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CoverPolygon(%v, %d) = %v, want %v", tt.name, tt.rings, tt.z, got, tt.want)
+		}
+	}
+}